@@ -1,108 +1,460 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
+	"math/bits"
+	"slices"
+	"sort"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// How eagerly a shard's new blocks propagate to its neighbour
+type IsolationLevel int
+
+const (
+	IsolationStrong   IsolationLevel = iota // sync as part of every add
+	IsolationEventual                       // queue the sync for flushDeferredSyncs
 )
 
 // Shard represents a mini-blockchain (shard) with blocks and a Merkle root
 type Shard struct {
 	Blocks     []Block
 	MerkleRoot string
+	Isolation  IsolationLevel
+	State      ShardState
+
+	acc [32]byte // XOR of all block hashes, updated as blocks are added or moved out
+}
+
+// New shard holding just its genesis block
+func newShard(shardIndex int, genesis Block) Shard {
+	shard := Shard{Blocks: []Block{genesis}, MerkleRoot: shardLeaf(shardIndex, genesis.Hash)}
+	shard.accumulate(genesis.Hash)
+	return shard
 }
 
+// Accumulator returns the incrementally maintained XOR accumulator of the shard's block hashes
+func (s Shard) Accumulator() string {
+	return hex.EncodeToString(s.acc[:])
+}
+
+// XORs a block hash into the accumulator; XOR is its own inverse, so this both adds and removes
+func (s *Shard) accumulate(hash string) {
+	hashBytes, _ := hex.DecodeString(hash)
+	for i := 0; i < len(s.acc) && i < len(hashBytes); i++ {
+		s.acc[i] ^= hashBytes[i]
+	}
+}
+
+// Height of a shard's tip (genesis is height 0)
+func shardHeight(shard Shard) int {
+	return len(shard.Blocks) - 1
+}
+
+// A Merkle Forest is a list of shards; a node's own view lives in merkleForest
+type Forest []Shard
+
 // Global Merkle Forest (list of shards)
 var merkleForest []Shard
 
-const (
-	shardCount       = 2 // number of shards
-	maxShardCapacity = 5 // maximum blocks in a shard before rebalancing
-)
+var shardCount = 2 // number of shards, overridden by the genesis config
+
+const defaultMaxShardCapacity = 5 // maximum blocks in a shard before rebalancing
+
+var maxShardCapacity = defaultMaxShardCapacity
+
+// Sets the shard capacity used by subsequent adds; n < 1 restores the default
+func SetMaxShardCapacity(n int) {
+	if n < 1 {
+		n = defaultMaxShardCapacity
+	}
+	maxShardCapacity = n
+}
 
 // Adds a block to the shard with fewest blocks (adaptive + dynamic rebalancing + consensus)
-func addBlockToShards(data string, validator string) {
-	// Smarter shard selection based on load score: fewer blocks + penalty for imbalance
+func addBlockToShards(data string, validator string) error {
+	defer lockForestWrite()()
+	return addBlockToShard(leastLoadedShard(), data, validator)
+}
+
+// Routes related submissions to the same shard by affinity key, falling back to the least-loaded shard once it is full
+func addBlockToShardsWithAffinity(data, validator, affinityKey string) error {
+	defer lockForestWrite()()
+	target := affinityShard(affinityKey)
+	if len(merkleForest[target].Blocks) >= maxShardCapacity {
+		target = leastLoadedShard()
+	}
+	return addBlockToShard(target, data, validator)
+}
+
+func affinityShard(key string) int {
+	shards := make([]int, len(merkleForest))
+	for i := range shards {
+		shards[i] = i
+	}
+	return routeKey(key, shards)
+}
+
+// Rendezvous (HRW) routing: the key goes to the shard with the highest hash of key and shard id.
+// When a shard joins or leaves, only keys won or lost by that shard move, about 1/n of them.
+// Returns -1 when shards is empty.
+func routeKey(key string, shards []int) int {
+	best, bestWeight := -1, uint64(0)
+	for _, shard := range shards {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d", key, shard)))
+		if weight := binary.BigEndian.Uint64(sum[:8]); best == -1 || weight > bestWeight || (weight == bestWeight && shard < best) {
+			best, bestWeight = shard, weight
+		}
+	}
+	return best
+}
+
+// Smarter shard selection based on load score: fewer blocks + penalty for imbalance
+// Shards outside the Active state are passed over unless none is Active.
+func leastLoadedShard() int {
 	target := 0
 	minScore := len(merkleForest[0].Blocks)
+	targetActive := merkleForest[0].State == ShardActive
 	for i := 1; i < len(merkleForest); i++ {
-		blockCount := len(merkleForest[i].Blocks)
-		loadScore := blockCount
-		if blockCount > maxShardCapacity-1 {
-			loadScore += 2 // temporary penalty
+		active := merkleForest[i].State == ShardActive
+		if targetActive && !active {
+			continue
 		}
-		if loadScore < minScore {
+		loadScore := shardLoadScore(merkleForest[i])
+		if loadScore < minScore || (active && !targetActive) {
+			targetActive = active
 			target = i
 			minScore = loadScore
 		}
 	}
+	return target
+}
+
+// Where and how a submitted block was committed
+type Receipt struct {
+	Shard    int
+	Height   int
+	Hash     string
+	Proposer string
+	View     int // consensus view that reached quorum
+}
 
+// Like addBlockToShards, but mining and consensus stop once ctx is done; a cancelled submission
+// leaves the forest unchanged
+func AddBlockCtx(ctx context.Context, data, validator string) (Receipt, error) {
+	defer lockForestWrite()()
+	return addBlockToShardCtx(ctx, leastLoadedShard(), data, validator)
+}
+
+// Mines, votes on and commits a block to the given shard, then rebalances and syncs
+func addBlockToShard(target int, data string, validator string) error {
+	_, err := addBlockToShardCtx(context.Background(), target, data, validator)
+	return err
+}
+
+// Rate-limited entry point for submissions
+func addBlockToShardCtx(ctx context.Context, target int, data string, validator string, txs ...Transaction) (Receipt, error) {
+	if err := submissionLimiter.Allow(validator); err != nil {
+		fmt.Println("Block rejected:", err)
+		return Receipt{}, err
+	}
+	return produceBlock(ctx, target, data, validator, txs...)
+}
+
+// Builds the template, runs consensus rounds and commits; no rate limit, for the node's own producer.
+// txs go first in the block, ahead of whatever the mempool contributes.
+func produceBlock(ctx context.Context, target int, data string, validator string, txs ...Transaction) (receipt Receipt, err error) {
+	ctx, span := tracer.Start(ctx, "addBlockToShards", trace.WithAttributes(
+		attribute.Int("shard.index", target),
+		attribute.String("validator", validator),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if nodeRole == RoleReplica {
+		fmt.Println("Block rejected:", errReplica)
+		return Receipt{}, errReplica
+	}
+	if err := shardWritable(target); err != nil {
+		fmt.Println("Block rejected:", err)
+		return Receipt{}, err
+	}
 	shard := &merkleForest[target]
 	prevBlock := shard.Blocks[len(shard.Blocks)-1]
-	newBlock := Block{
-		Index:     prevBlock.Index + 1,
-		Timestamp: time.Now().String(),
-		Data:      data,
-		PrevHash:  prevBlock.Hash,
-		Validator: validator,
+	packState := ledger
+	if len(txs) > 0 {
+		packState = ledger.clone()
+		if err := packState.applyBlock(Block{BlockBody: BlockBody{Transactions: txs}}); err != nil {
+			fmt.Println("Block rejected:", err)
+			return Receipt{}, err
+		}
+	}
+	gasLeft := blockGasLimit - min(totalGas(txs), blockGasLimit)
+	template := Block{
+		BlockHeader: BlockHeader{Index: prevBlock.Index + 1, Timestamp: formatBlockTime(time.Now()), PrevHash: prevBlock.Hash},
+		BlockBody:   BlockBody{Data: data, Transactions: slices.Concat(txs, mempool.Pack(packState, gasLeft, prevBlock.Index+1))},
+	}
+	if key, ok := shardKeys[target]; ok {
+		payload, err := encryptPayload(key, data)
+		if err != nil {
+			fmt.Println("Block rejected:", err)
+			return Receipt{}, err
+		}
+		template.Data = payload
+		template.Encrypted = true
 	}
-	newBlock.Nonce = mineBlock(newBlock)
-	newBlock.Hash = calculateHash(newBlock)
 
-	if dBFTConsensus(newBlock) {
-		shard.Blocks = append(shard.Blocks, newBlock)
-		shard.MerkleRoot = updateMerkleRoot(shard.Blocks)
+	newBlock, view, err := runConsensusRounds(ctx, template, validator)
+	if err != nil {
+		fmt.Println("Block rejected by dBFT:", err)
+		return Receipt{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		fmt.Println("Block abandoned:", err)
+		return Receipt{}, err
+	}
+	if err := validateInclusionList(newBlock, *shard); err != nil {
+		fmt.Println("Block rejected:", err)
+		return Receipt{}, err
+	}
+	if err := acceptBlock(target, newBlock); err != nil {
+		fmt.Println("Block rejected:", err)
+		return Receipt{}, err
+	}
+	proposerLog.record(target, newBlock, view, validator)
+	if err := SaveValidators(); err != nil {
+		fmt.Println("Storage error:", err)
+	}
+	receipt = Receipt{
+		Shard:    target,
+		Height:   len(shard.Blocks) - 1,
+		Hash:     newBlock.Hash,
+		Proposer: newBlock.Validator,
+		View:     view,
+	}
 
-		updateAMQ(target, newBlock.Hash) // ← Add this line
+	if len(shard.Blocks) > maxShardCapacity {
+		rebalanceShards()
+	}
 
-		if len(shard.Blocks) > maxShardCapacity {
-			rebalanceShards()
+	syncAfterAdd(ctx, target, (target+1)%len(merkleForest))
+	return receipt, nil
+}
+
+// A sync an Eventual shard put off: the block is found again by hash when the queue is flushed
+type deferredSync struct {
+	Source int
+	Target int
+	Hash   string
+}
+
+var deferredSyncs []deferredSync
+
+// Syncs the source tip now for a Strong shard, queues it for an Eventual one
+func syncAfterAdd(ctx context.Context, source, target int) {
+	if merkleForest[source].Isolation == IsolationEventual {
+		blocks := merkleForest[source].Blocks
+		deferredSyncs = append(deferredSyncs, deferredSync{source, target, blocks[len(blocks)-1].Hash})
+		return
+	}
+	_, span := tracer.Start(ctx, "synchronizeStateAcrossShards", trace.WithAttributes(
+		attribute.Int("shard.source", source),
+		attribute.Int("shard.target", target),
+	))
+	synchronizeStateAcrossShards(source, target)
+	span.End()
+}
+
+// Runs the queued syncs in order, skipping blocks that have since left their source shard;
+// returns how many ran
+func flushDeferredSyncs() int {
+	pending := deferredSyncs
+	deferredSyncs = nil
+	ran := 0
+	for _, sync := range pending {
+		for pos, block := range merkleForest[sync.Source].Blocks {
+			if block.Hash == sync.Hash {
+				synchronizeBlockAcrossShards(sync.Source, pos, sync.Target)
+				ran++
+				break
+			}
 		}
+	}
+	return ran
+}
 
-		synchronizeStateAcrossShards(target, (target+1)%len(merkleForest))
-	} else {
-		fmt.Println("Block rejected by dBFT.")
+// Load score used for shard selection: block count plus a penalty near capacity
+func shardLoadScore(shard Shard) int {
+	blockCount := len(shard.Blocks)
+	loadScore := blockCount
+	if blockCount > maxShardCapacity-1 {
+		loadScore += 2 // temporary penalty
 	}
+	return loadScore
+}
+
+// Per-shard load report
+type ShardStat struct {
+	Index          int    `json:"index"`
+	BlockCount     int    `json:"blockCount"`
+	MerkleRoot     string `json:"merkleRoot"`
+	DifficultyBits int    `json:"difficultyBits"`
+	LoadScore      int    `json:"loadScore"`
+	Finalized      int    `json:"finalized"` // position of the highest final block, -1 if none
 }
 
-// Merkle Root update for any block list
-func updateMerkleRoot(blocks []Block) string {
-	if len(blocks) == 0 {
-		return ""
+func ShardStats() []ShardStat {
+	var stats []ShardStat
+	for i, shard := range merkleForest {
+		stats = append(stats, ShardStat{
+			Index:          i,
+			BlockCount:     len(shard.Blocks),
+			MerkleRoot:     shard.MerkleRoot,
+			DifficultyBits: difficultyBits,
+			LoadScore:      shardLoadScore(shard),
+			Finalized:      finalizedHeight(i),
+		})
 	}
-	var hashes []string
-	for _, block := range blocks {
-		hashes = append(hashes, block.Hash)
+	return stats
+}
+
+// Forest-wide imbalance: block count of the fullest shard minus the emptiest
+func ForestImbalance() int {
+	if len(merkleForest) == 0 {
+		return 0
 	}
-	for len(hashes) > 1 {
-		var newLevel []string
-		for i := 0; i < len(hashes); i += 2 {
-			right := hashes[i]
-			if i+1 < len(hashes) {
-				right = hashes[i+1]
-			}
-			combined := hashes[i] + right
-			sum := sha256.Sum256([]byte(combined))
-			newLevel = append(newLevel, hex.EncodeToString(sum[:]))
+	maxCount, minCount := len(merkleForest[0].Blocks), len(merkleForest[0].Blocks)
+	for _, shard := range merkleForest[1:] {
+		maxCount = max(maxCount, len(shard.Blocks))
+		minCount = min(minCount, len(shard.Blocks))
+	}
+	return maxCount - minCount
+}
+
+// Shard with the greatest cumulative proof of work (ties go to the lower index); -1 for an empty forest.
+// Work counts the leading zero bits each hash actually achieved, so a few hard blocks can outweigh
+// many easy ones.
+func HeaviestShard() (index int, work uint64) {
+	index = -1
+	for i, shard := range merkleForest {
+		if w := chainWork(shard.Blocks); index == -1 || w > work {
+			index, work = i, w
 		}
-		hashes = newLevel
 	}
-	return hashes[0]
+	return index, work
+}
+
+// Imports an externally produced block onto a shard after running the block validators
+func ImportBlock(shardIndex int, block Block) error {
+	defer lockForestWrite()()
+	if shardIndex < 0 || shardIndex >= len(merkleForest) {
+		return fmt.Errorf("shard %d out of range", shardIndex)
+	}
+	return acceptBlock(shardIndex, block)
+}
+
+// Duplicate check, block validators, then ledger application; the block is committed only if all pass
+func acceptBlock(shardIndex int, block Block) error {
+	if err := shardWritable(shardIndex); err != nil {
+		return err
+	}
+	if err := checkDuplicate(shardIndex, block); err != nil {
+		return err
+	}
+	if err := runBlockValidators(block, merkleForest[shardIndex]); err != nil {
+		return err
+	}
+	if err := ledger.applyBlock(block); err != nil {
+		return err
+	}
+	commitBlock(shardIndex, block)
+	mempool.blockAccepted()
+	runAcceptHooks(shardIndex, block)
+	publishBlock(BlockEvent{Shard: shardIndex, Position: len(merkleForest[shardIndex].Blocks) - 1, Block: block})
+	return nil
+}
+
+// Appends a validated block and updates the shard root, AMQ and store
+func commitBlock(shardIndex int, block Block) {
+	shard := &merkleForest[shardIndex]
+	shard.Blocks = append(shard.Blocks, block)
+	shard.MerkleRoot = updateMerkleRoot(shardIndex, shard.Blocks)
+	shard.accumulate(block.Hash)
+
+	updateAMQ(shardIndex, block.Hash)
+	if err := persistShard(shardIndex, len(shard.Blocks)-1); err != nil {
+		fmt.Println("Storage error:", err)
+	}
+	logEvent(EventBlockAdded, shardIndex, -1, block.Hash, "")
+}
+
+// Merkle Root update for a shard's block list
+func updateMerkleRoot(shardIndex int, blocks []Block) string {
+	return merkleRootOfHashes(shardLeaves(shardIndex, blocks))
 }
 
-// Merkle Proof generator
+// Leaf for a block in a shard tree. The shard index is hashed in, so a proof for one shard
+// never verifies against another, even if their roots were built from the same blocks.
+func shardLeaf(shardIndex int, blockHash string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("shard:%d:%s", shardIndex, blockHash)))
+	return hex.EncodeToString(sum[:])
+}
+
+func shardLeaves(shardIndex int, blocks []Block) []string {
+	leaves := make([]string, len(blocks))
+	for i, block := range blocks {
+		leaves[i] = shardLeaf(shardIndex, block.Hash)
+	}
+	return leaves
+}
+
+// Merkle Proof generator; nil for an unknown shard or block, including empty shards
 func generateMerkleProof(shardIndex, blockIndex int) []string {
-	blocks := merkleForest[shardIndex].Blocks
-	if blockIndex >= len(blocks) {
+	if !hasBlock(shardIndex, blockIndex) {
 		return nil
 	}
-	var level []string
+	shard := merkleForest[shardIndex]
+	return merkleProofCache.get(shardIndex, blockIndex, shard.MerkleRoot, func() []string {
+		return merkleProofOfHashes(shardLeaves(shardIndex, shard.Blocks), blockIndex)
+	})
+}
+
+// Reports whether the shard exists and holds a block at blockIndex
+func hasBlock(shardIndex, blockIndex int) bool {
+	if shardIndex < 0 || shardIndex >= len(merkleForest) {
+		return false
+	}
+	return blockIndex >= 0 && blockIndex < len(merkleForest[shardIndex].Blocks)
+}
+
+func blockHashes(blocks []Block) []string {
+	var hashes []string
 	for _, block := range blocks {
-		level = append(level, block.Hash)
+		hashes = append(hashes, block.Hash)
 	}
+	return hashes
+}
+
+// Merkle root over leaf hashes (an odd node out is paired with itself)
+func merkleRootOfHashes(hashes []string) string {
+	return merkleRootKary(hashes, 2)
+}
+
+// Sibling path from the leaf at index up to the root
+func merkleProofOfHashes(hashes []string, index int) []string {
+	if index < 0 || index >= len(hashes) {
+		return nil
+	}
+	level := hashes
 	var proof []string
-	index := blockIndex
 	for len(level) > 1 {
 		var nextLevel []string
 		for i := 0; i < len(level); i += 2 {
@@ -129,8 +481,61 @@ func generateMerkleProof(shardIndex, blockIndex int) []string {
 	return proof
 }
 
+// Chain from genesis up to the given block, following PrevHash links within the shard
+func AncestorsOf(shardIndex, blockIndex int) ([]Block, error) {
+	if shardIndex < 0 || shardIndex >= len(merkleForest) {
+		return nil, fmt.Errorf("shard %d out of range", shardIndex)
+	}
+	blocks := merkleForest[shardIndex].Blocks
+	if blockIndex < 0 || blockIndex >= len(blocks) {
+		return nil, fmt.Errorf("block %d out of range in shard %d", blockIndex, shardIndex)
+	}
+	byHash := make(map[string]Block, len(blocks))
+	for _, block := range blocks {
+		byHash[block.Hash] = block
+	}
+
+	var chain []Block
+	visited := make(map[string]bool)
+	current := blocks[blockIndex]
+	for {
+		if visited[current.Hash] {
+			return nil, fmt.Errorf("cycle at block %s", shortHash(current.Hash))
+		}
+		visited[current.Hash] = true
+		chain = append(chain, current)
+		if current.PrevHash == "" {
+			break
+		}
+		parent, ok := byHash[current.PrevHash]
+		if !ok {
+			return nil, fmt.Errorf("broken link: block %d references missing parent %s", current.Index, shortHash(current.PrevHash))
+		}
+		current = parent
+	}
+
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// One block transfer between shards: the current tail of From, identified by Hash, goes to the tail of To
+type Move struct {
+	From int
+	To   int
+	Hash string
+}
+
 // Rebalance by transferring blocks between shards
 func rebalanceShards() {
+	if err := executeRebalance(planSingleMove()); err != nil {
+		fmt.Println("Rebalance error:", err)
+	}
+}
+
+// At most one move, from the fullest to the emptiest shard, when they differ by more than one block
+func planSingleMove() []Move {
 	var maxShardIndex, minShardIndex int
 	maxBlockCount := 0
 	minBlockCount := len(merkleForest[0].Blocks)
@@ -147,46 +552,245 @@ func rebalanceShards() {
 		}
 	}
 
-	if maxShardIndex != minShardIndex && maxBlockCount-minBlockCount > 1 {
-		blockToMove := merkleForest[maxShardIndex].Blocks[len(merkleForest[maxShardIndex].Blocks)-1]
-		merkleForest[maxShardIndex].Blocks = merkleForest[maxShardIndex].Blocks[:len(merkleForest[maxShardIndex].Blocks)-1]
-		merkleForest[minShardIndex].Blocks = append(merkleForest[minShardIndex].Blocks, blockToMove)
+	if maxShardIndex == minShardIndex || maxBlockCount-minBlockCount <= 1 {
+		return nil
+	}
+	blocks := merkleForest[maxShardIndex].Blocks
+	return []Move{{From: maxShardIndex, To: minShardIndex, Hash: blocks[len(blocks)-1].Hash}}
+}
+
+// One-shot redistribution: moves tail blocks from overfull to underfull shards until
+// every shard is within one block of the others, then refreshes roots, AMQs and the store
+func rebalanceAll() {
+	if err := executeRebalance(PlanRebalance()); err != nil {
+		fmt.Println("Rebalance error:", err)
+	}
+}
 
-		merkleForest[maxShardIndex].MerkleRoot = updateMerkleRoot(merkleForest[maxShardIndex].Blocks)
-		merkleForest[minShardIndex].MerkleRoot = updateMerkleRoot(merkleForest[minShardIndex].Blocks)
+// Dry run of rebalanceAll: the moves it would make, in order, without touching the forest
+func PlanRebalance() []Move {
+	n := len(merkleForest)
+	if n == 0 {
+		return nil
+	}
+	total := 0
+	order := make([]int, n)
+	tails := make([][]string, n) // simulated hash stacks, popped and pushed as moves are planned
+	for i, shard := range merkleForest {
+		total += len(shard.Blocks)
+		order[i] = i
+		tails[i] = blockHashes(shard.Blocks)
+	}
+	// The fullest shards keep the remainder, which minimizes the number of moves
+	sort.SliceStable(order, func(a, b int) bool {
+		return len(merkleForest[order[a]].Blocks) > len(merkleForest[order[b]].Blocks)
+	})
+	targets := make([]int, n)
+	for rank, i := range order {
+		targets[i] = total / n
+		if rank < total%n {
+			targets[i]++
+		}
+	}
+
+	var plan []Move
+	receiver := 0
+	for donor := range tails {
+		for len(tails[donor]) > targets[donor] {
+			for len(tails[receiver]) >= targets[receiver] {
+				receiver++
+			}
+			hash := tails[donor][len(tails[donor])-1]
+			tails[donor] = tails[donor][:len(tails[donor])-1]
+			tails[receiver] = append(tails[receiver], hash)
+			plan = append(plan, Move{From: donor, To: receiver, Hash: hash})
+		}
 	}
+	return plan
+}
+
+// Carries out a plan in order. The whole plan is checked first, so a stale plan whose moves no
+// longer match the shard tails is rejected before anything changes.
+func ExecuteRebalance(plan []Move) error {
+	defer lockForestWrite()()
+	return executeRebalance(plan)
+}
+
+func executeRebalance(plan []Move) error {
+	tails := make([][]string, len(merkleForest))
+	for i, shard := range merkleForest {
+		tails[i] = blockHashes(shard.Blocks)
+	}
+	for k, move := range plan {
+		if move.From < 0 || move.From >= len(tails) || move.To < 0 || move.To >= len(tails) || move.From == move.To {
+			return fmt.Errorf("move %d: bad shards %d -> %d", k, move.From, move.To)
+		}
+		if merkleForest[move.From].State != ShardActive || merkleForest[move.To].State != ShardActive {
+			return fmt.Errorf("move %d: shard %d -> %d is not Active: %w", k, move.From, move.To, errShardBusy)
+		}
+		from := tails[move.From]
+		if len(from) <= 1 || from[len(from)-1] != move.Hash {
+			return fmt.Errorf("move %d: %s is not the tail of shard %d", k, shortHash(move.Hash), move.From)
+		}
+		tails[move.From] = from[:len(from)-1]
+		tails[move.To] = append(tails[move.To], move.Hash)
+	}
+
+	firstChanged := make(map[int]int) // lowest position rewritten per touched shard
+	markChanged := func(i, pos int) {
+		if prev, ok := firstChanged[i]; !ok || pos < prev {
+			firstChanged[i] = pos
+		}
+	}
+	for _, move := range plan {
+		blocks := merkleForest[move.From].Blocks
+		block := blocks[len(blocks)-1]
+		merkleForest[move.From].Blocks = blocks[:len(blocks)-1]
+		merkleForest[move.From].accumulate(block.Hash)
+		markChanged(move.From, len(blocks)-1)
+
+		markChanged(move.To, len(merkleForest[move.To].Blocks))
+		merkleForest[move.To].Blocks = append(merkleForest[move.To].Blocks, block)
+		merkleForest[move.To].accumulate(block.Hash)
+		updateAMQ(move.To, block.Hash)
+		logEvent(EventRebalanced, move.To, move.From, block.Hash, "")
+	}
+
+	for i, from := range firstChanged {
+		merkleForest[i].MerkleRoot = updateMerkleRoot(i, merkleForest[i].Blocks)
+		if err := persistShard(i, from); err != nil {
+			fmt.Println("Storage error:", err)
+		}
+	}
+	return nil
 }
 
 // Updates Merkle roots across all shards
 func synchronizeShards() {
 	for i := range merkleForest {
-		merkleForest[i].MerkleRoot = updateMerkleRoot(merkleForest[i].Blocks)
+		merkleForest[i].MerkleRoot = updateMerkleRoot(i, merkleForest[i].Blocks)
+	}
+}
+
+// Indices of shards whose stored MerkleRoot differs from the root recomputed from their blocks
+func auditMerkleRoots() []int {
+	var drifted []int
+	for i, shard := range merkleForest {
+		if shard.MerkleRoot != updateMerkleRoot(i, shard.Blocks) {
+			drifted = append(drifted, i)
+		}
 	}
+	return drifted
+}
+
+// Recomputes and persists the roots flagged by auditMerkleRoots, returning the repaired indices
+func repairMerkleRoots() []int {
+	drifted := auditMerkleRoots()
+	for _, i := range drifted {
+		fmt.Printf("Repairing Merkle root of shard %d\n", i)
+		merkleForest[i].MerkleRoot = updateMerkleRoot(i, merkleForest[i].Blocks)
+		if err := persistShard(i, len(merkleForest[i].Blocks)); err != nil {
+			fmt.Println("Storage error:", err)
+		}
+	}
+	return drifted
+}
+
+// Forest root: Merkle root over shard roots in shard order, a single commitment to the whole forest
+func ForestRoot() string {
+	return merkleRootOfHashes(shardRoots())
+}
+
+func shardRoots() []string {
+	var roots []string
+	for _, shard := range merkleForest {
+		roots = append(roots, shard.MerkleRoot)
+	}
+	return roots
+}
+
+// Two-level inclusion proof: block → shard root → forest root
+type ForestProof struct {
+	ShardIndex int
+	BlockIndex int
+	BlockHash  string
+	BlockProof []string
+	ShardRoot  string
+	ShardProof []string
+}
+
+// Forest proof generator (zero value for out-of-range indices)
+func GenerateForestProof(shardIndex, blockIndex int) ForestProof {
+	if shardIndex < 0 || shardIndex >= len(merkleForest) {
+		return ForestProof{}
+	}
+	shard := merkleForest[shardIndex]
+	if blockIndex < 0 || blockIndex >= len(shard.Blocks) {
+		return ForestProof{}
+	}
+	return ForestProof{
+		ShardIndex: shardIndex,
+		BlockIndex: blockIndex,
+		BlockHash:  shard.Blocks[blockIndex].Hash,
+		BlockProof: generateMerkleProof(shardIndex, blockIndex),
+		ShardRoot:  shard.MerkleRoot,
+		ShardProof: merkleProofOfHashes(shardRoots(), shardIndex),
+	}
+}
+
+// Forest proof validator: both levels must fold up to the given forest root
+func VerifyForestProof(proof ForestProof, forestRoot string) bool {
+	if proof.BlockHash == "" {
+		return false
+	}
+	if !verifyMerkleProofOfHashes(shardLeaf(proof.ShardIndex, proof.BlockHash), proof.BlockIndex, proof.BlockProof, proof.ShardRoot) {
+		return false
+	}
+	return verifyMerkleProofOfHashes(proof.ShardRoot, proof.ShardIndex, proof.ShardProof, forestRoot)
 }
 
 // Cross-shard state sync using Merkle proof
 func synchronizeStateAcrossShards(sourceShardIndex, targetShardIndex int) {
+	synchronizeBlockAcrossShards(sourceShardIndex, len(merkleForest[sourceShardIndex].Blocks)-1, targetShardIndex)
+}
+
+// Transfers the block at blockIndex of the source shard, once its Merkle proof checks out
+func synchronizeBlockAcrossShards(sourceShardIndex, blockIndex, targetShardIndex int) {
 	sourceShard := &merkleForest[sourceShardIndex]
 	targetShard := &merkleForest[targetShardIndex]
 
-	lastBlockIndex := len(sourceShard.Blocks) - 1
-	proof := generateMerkleProof(sourceShardIndex, lastBlockIndex)
-	blockToTransfer := sourceShard.Blocks[lastBlockIndex]
+	proof := generateMerkleProof(sourceShardIndex, blockIndex)
+	blockToTransfer := sourceShard.Blocks[blockIndex]
 
-	if validateMerkleProof(sourceShardIndex, lastBlockIndex, proof) {
+	if shardContains(targetShardIndex, blockToTransfer.Hash) {
+		return
+	}
+	if validateMerkleProof(sourceShardIndex, blockIndex, proof) {
 		targetShard.Blocks = append(targetShard.Blocks, blockToTransfer)
+		targetShard.accumulate(blockToTransfer.Hash)
+		updateAMQ(targetShardIndex, blockToTransfer.Hash)
 		synchronizeShards()
+		if err := persistShard(targetShardIndex, len(targetShard.Blocks)-1); err != nil {
+			fmt.Println("Storage error:", err)
+		}
+		logEvent(EventSynced, targetShardIndex, sourceShardIndex, blockToTransfer.Hash, "")
 	} else {
 		fmt.Println("Merkle proof validation failed, aborting state transfer.")
 	}
 }
 
-// Merkle Proof validator
+// Merkle Proof validator; false when the shard has no such block
 func validateMerkleProof(shardIndex, blockIndex int, proof []string) bool {
-	leaf := merkleForest[shardIndex].Blocks[blockIndex].Hash
-	index := blockIndex
-	hash := leaf
+	if !hasBlock(shardIndex, blockIndex) {
+		return false
+	}
+	leaf := shardLeaf(shardIndex, merkleForest[shardIndex].Blocks[blockIndex].Hash)
+	return verifyMerkleProofOfHashes(leaf, blockIndex, proof, merkleForest[shardIndex].MerkleRoot)
+}
 
+// Folds a sibling path onto a leaf and compares against the expected root
+func verifyMerkleProofOfHashes(leaf string, index int, proof []string, root string) bool {
+	hash := leaf
 	for _, sibling := range proof {
 		var combined string
 		if index%2 == 0 {
@@ -199,7 +803,7 @@ func validateMerkleProof(shardIndex, blockIndex int, proof []string) bool {
 		index /= 2
 	}
 
-	return hash == merkleForest[shardIndex].MerkleRoot
+	return hashesEqual(hash, root)
 }
 
 // Not used directly but kept for completeness
@@ -209,28 +813,120 @@ func calculateHashForProof(leftHash, rightHash string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// AMQ Filter (simplified): tracks recent block hashes for efficient presence check
+const (
+	amqFilterBits   = 4096 // bits per shard's Bloom filter
+	amqFilterHashes = 4    // bit positions set per hash
+)
+
+// AMQ Filter: a Bloom filter over a shard's block hashes. It never misses a hash it was given
+// but may report one it wasn't, so shardContains confirms hits with a scan. Hashes of blocks
+// moved out of the shard stay set until the filter is rebuilt.
 type AMQFilter struct {
-	HashSet map[string]bool
+	bits []uint64
 }
 
 var amqFilters []AMQFilter
 
+func newAMQFilter() AMQFilter {
+	return AMQFilter{bits: make([]uint64, amqFilterBits/64)}
+}
+
+// Bit positions for hash, by double hashing over its SHA-256
+func amqPositions(hash string) [amqFilterHashes]uint {
+	sum := sha256.Sum256([]byte(hash))
+	h1, h2 := binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:16])|1
+	var positions [amqFilterHashes]uint
+	for i := range positions {
+		positions[i] = uint((h1 + uint64(i)*h2) % amqFilterBits)
+	}
+	return positions
+}
+
+func (f AMQFilter) Add(hash string) {
+	for _, p := range amqPositions(hash) {
+		f.bits[p/64] |= 1 << (p % 64)
+	}
+}
+
+// False when hash was certainly never added
+func (f AMQFilter) MayContain(hash string) bool {
+	for _, p := range amqPositions(hash) {
+		if f.bits[p/64]&(1<<(p%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Share of the filter's bits that are set
+func (f AMQFilter) FillRatio() float64 {
+	set := 0
+	for _, word := range f.bits {
+		set += bits.OnesCount64(word)
+	}
+	return float64(set) / float64(len(f.bits)*64)
+}
+
+// Estimated chance that MayContain answers true for a hash never added: every one of its bit
+// positions is already set. Only grows as hashes are added.
+func (f AMQFilter) FalsePositiveRate() float64 {
+	return math.Pow(f.FillRatio(), amqFilterHashes)
+}
+
 // Initialize AMQ filters
 func initAMQFilters() {
+	amqFilters = nil
 	for i := 0; i < shardCount; i++ {
-		amqFilters = append(amqFilters, AMQFilter{HashSet: make(map[string]bool)})
+		amqFilters = append(amqFilters, newAMQFilter())
+	}
+}
+
+// Fresh filters holding every block hash currently in each shard
+func rebuildAMQFilters() {
+	amqFilters = make([]AMQFilter, len(merkleForest))
+	for i, shard := range merkleForest {
+		amqFilters[i] = newAMQFilter()
+		for _, block := range shard.Blocks {
+			updateAMQ(i, block.Hash)
+		}
 	}
 }
 
-// Update AMQ when block added
+// Update AMQ when block added, warning once the filter's false positive rate reaches amqAlertRate
 func updateAMQ(shardIndex int, hash string) {
-	amqFilters[shardIndex].HashSet[hash] = true
+	f := amqFilters[shardIndex]
+	before := f.FalsePositiveRate()
+	f.Add(hash)
+	if rate := f.FalsePositiveRate(); before < amqAlertRate && rate >= amqAlertRate {
+		fmt.Printf("Warning: AMQ filter of shard %d is saturating (false positive rate %.4f)\n", shardIndex, rate)
+	}
 }
 
 // Check block presence using AMQ
 func isInAMQ(shardIndex int, hash string) bool {
-	return amqFilters[shardIndex].HashSet[hash]
+	return amqFilters[shardIndex].MayContain(hash)
+}
+
+var errDuplicateBlock = errors.New("duplicate block")
+
+// Definitive presence check: the AMQ rules out most absent hashes, a scan confirms any hit
+func shardContains(shardIndex int, hash string) bool {
+	if !isInAMQ(shardIndex, hash) {
+		return false
+	}
+	for _, block := range merkleForest[shardIndex].Blocks {
+		if block.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+func checkDuplicate(shardIndex int, block Block) error {
+	if shardContains(shardIndex, block.Hash) {
+		return fmt.Errorf("shard %d already holds block %s: %w", shardIndex, shortHash(block.Hash), errDuplicateBlock)
+	}
+	return nil
 }
 
 // Probabilistic Merkle proof compression (truncate each hash to first 8 chars)
@@ -246,8 +942,13 @@ func compressMerkleProof(proof []string) []string {
 
 // Cryptographic accumulator snapshot (accumulated XOR of hashes)
 func getAccumulatorSnapshot(shardIndex int) string {
+	return merkleForest[shardIndex].Accumulator()
+}
+
+// Full recompute of the XOR accumulator over any block list
+func accumulatorOf(blocks []Block) string {
 	acc := make([]byte, 32)
-	for _, block := range merkleForest[shardIndex].Blocks {
+	for _, block := range blocks {
 		hashBytes, _ := hex.DecodeString(block.Hash)
 		for i := range acc {
 			acc[i] ^= hashBytes[i]
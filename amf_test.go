@@ -0,0 +1,653 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var benchSizes = []int{10, 100, 1000}
+
+// Shard of n blocks with distinct hashes; not mined, so it suits root, proof and AMQ work only
+func benchShard(shardIndex, n int) Shard {
+	blocks := make([]Block, n)
+	for i := range blocks {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("bench %d %d", shardIndex, i)))
+		blocks[i] = Block{BlockHeader: BlockHeader{Index: i, Hash: hex.EncodeToString(sum[:])}}
+	}
+	shard := newShard(shardIndex, blocks[0])
+	for _, block := range blocks[1:] {
+		shard.Blocks = append(shard.Blocks, block)
+		shard.accumulate(block.Hash)
+	}
+	shard.MerkleRoot = updateMerkleRoot(shardIndex, shard.Blocks)
+	return shard
+}
+
+func BenchmarkMineBlock(b *testing.B) {
+	block := Block{BlockHeader: BlockHeader{Timestamp: formatBlockTime(genesisTime)}}
+	for i := 0; i < b.N; i++ {
+		block.Index = i
+		if _, err := mineBlockAt(block, 8); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUpdateMerkleRoot(b *testing.B) {
+	for _, n := range benchSizes {
+		blocks := benchShard(0, n).Blocks
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				updateMerkleRoot(0, blocks)
+			}
+		})
+	}
+}
+
+func BenchmarkGenerateMerkleProof(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			defer InstallTestForest(Forest{benchShard(0, n)})()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				generateMerkleProof(0, i%n)
+			}
+		})
+	}
+}
+
+// Proof generation without the proof cache in front of it
+func BenchmarkMerkleProofOfHashes(b *testing.B) {
+	for _, n := range benchSizes {
+		leaves := shardLeaves(0, benchShard(0, n).Blocks)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				merkleProofOfHashes(leaves, i%n)
+			}
+		})
+	}
+}
+
+func BenchmarkValidateMerkleProof(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			defer InstallTestForest(Forest{benchShard(0, n)})()
+			proofs := make([][]string, n)
+			for i := range proofs {
+				proofs[i] = generateMerkleProof(0, i)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if !validateMerkleProof(0, i%n, proofs[i%n]) {
+					b.Fatalf("proof for block %d rejected", i%n)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetAccumulatorSnapshot(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			defer InstallTestForest(Forest{benchShard(0, n)})()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				getAccumulatorSnapshot(0)
+			}
+		})
+	}
+}
+
+func BenchmarkAccumulatorOf(b *testing.B) {
+	for _, n := range benchSizes {
+		blocks := benchShard(0, n).Blocks
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				accumulatorOf(blocks)
+			}
+		})
+	}
+}
+
+func BenchmarkAMQAdd(b *testing.B) {
+	hashes := blockHashes(benchShard(0, 1000).Blocks)
+	f := newAMQFilter()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.Add(hashes[i%len(hashes)])
+	}
+}
+
+func BenchmarkAMQMayContain(b *testing.B) {
+	present := blockHashes(benchShard(0, 1000).Blocks)
+	absent := blockHashes(benchShard(1, 1000).Blocks)
+	f := newAMQFilter()
+	for _, hash := range present {
+		f.Add(hash)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.MayContain(present[i%len(present)])
+		f.MayContain(absent[i%len(absent)])
+	}
+}
+
+// Duplicate check as acceptBlock runs it: the AMQ rules out new hashes, a scan confirms hits
+func BenchmarkShardContains(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			shard := benchShard(0, n)
+			defer InstallTestForest(Forest{shard})()
+			absent := blockHashes(benchShard(1, n).Blocks)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				shardContains(0, absent[i%n])
+				shardContains(0, shard.Blocks[i%n].Hash)
+			}
+		})
+	}
+}
+
+func TestAccumulatorMatchesFullRecompute(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 2})
+	check := func(when string) {
+		t.Helper()
+		for i, shard := range merkleForest {
+			if got, want := shard.Accumulator(), accumulatorOf(shard.Blocks); got != want {
+				t.Fatalf("%s: shard %d accumulator %.12s, full recompute %.12s", when, i, got, want)
+			}
+		}
+	}
+	check("genesis")
+
+	for i := 0; i < 3; i++ {
+		tip := merkleForest[0].Blocks[len(merkleForest[0].Blocks)-1]
+		if err := acceptBlock(0, MineTestBlockAfter(tip, fmt.Sprint("block ", i))); err != nil {
+			t.Fatal(err)
+		}
+		check(fmt.Sprint("after block ", i))
+	}
+
+	tail := merkleForest[0].Blocks[len(merkleForest[0].Blocks)-1]
+	if err := ExecuteRebalance([]Move{{From: 0, To: 1, Hash: tail.Hash}}); err != nil {
+		t.Fatal(err)
+	}
+	check("after moving a block")
+}
+
+func TestImportingTheSameBlockTwice(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1})
+	block := MineTestBlockAfter(merkleForest[0].Blocks[0], "once")
+
+	if err := ImportBlock(0, block); err != nil {
+		t.Fatal(err)
+	}
+	if err := ImportBlock(0, block); !errors.Is(err, errDuplicateBlock) {
+		t.Fatalf("second import: %v, want errDuplicateBlock", err)
+	}
+	copies := 0
+	for _, b := range merkleForest[0].Blocks {
+		if b.Hash == block.Hash {
+			copies++
+		}
+	}
+	if copies != 1 {
+		t.Fatalf("shard holds %d copies of the block", copies)
+	}
+}
+
+func TestRebalanceReducesImbalance(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 2})
+	for i := 0; i < 5; i++ {
+		tip := merkleForest[0].Blocks[len(merkleForest[0].Blocks)-1]
+		if err := acceptBlock(0, MineTestBlockAfter(tip, fmt.Sprint("lopsided ", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := ShardStats()
+	if len(stats) != 2 || stats[0].BlockCount != 6 || stats[1].BlockCount != 1 || stats[0].MerkleRoot != merkleForest[0].MerkleRoot {
+		t.Fatalf("stats %+v do not match the forest", stats)
+	}
+	before := ForestImbalance()
+	if before <= 2 {
+		t.Fatalf("imbalance %d after lopsided additions, want above 2", before)
+	}
+
+	rebalanceShards()
+	if after := ForestImbalance(); after >= before {
+		t.Fatalf("imbalance %d after rebalancing, was %d", after, before)
+	}
+}
+
+func TestAffinityKeepsRelatedBlocksTogetherUntilFull(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 3})
+	defer useConsensusStubs(fixedVote(true), &countingProofProvider{})()
+	defer SetMaxShardCapacity(maxShardCapacity)
+	SetMaxShardCapacity(4)
+	submissionLimiter.SetLimit("Validator1", RateLimit{Rate: 1, Burst: 10})
+	for i := range merkleForest {
+		merkleForest[i].Isolation = IsolationEventual // keep syncs from copying blocks around
+	}
+	home := affinityShard("alice")
+
+	shardOf := func(data string) int {
+		for i, shard := range merkleForest {
+			for _, block := range shard.Blocks {
+				if block.Data == data {
+					return i
+				}
+			}
+		}
+		return -1
+	}
+	for i := 0; i < 6; i++ {
+		data := fmt.Sprint("alice ", i)
+		if err := addBlockToShardsWithAffinity(data, "Validator1", "alice"); err != nil {
+			t.Fatal(err)
+		}
+		// Genesis plus three affinity blocks fill the home shard
+		if got, full := shardOf(data), i >= 3; (got == home) == full {
+			t.Fatalf("submission %d landed in shard %d, home shard %d full %t", i, got, home, full)
+		}
+	}
+	if len(merkleForest[home].Blocks) != 4 {
+		t.Fatalf("home shard holds %d blocks, want its capacity of 4", len(merkleForest[home].Blocks))
+	}
+}
+
+func TestMerkleRootDriftIsAuditedAndRepaired(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 3})
+	if drifted := auditMerkleRoots(); len(drifted) != 0 {
+		t.Fatalf("fresh forest flagged shards %v", drifted)
+	}
+	want := merkleForest[1].MerkleRoot
+	merkleForest[1].MerkleRoot = strings.Repeat("0", 64)
+	if err := persistShard(1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if drifted := auditMerkleRoots(); fmt.Sprint(drifted) != "[1]" {
+		t.Fatalf("audit flagged %v, want [1]", drifted)
+	}
+	if repaired := repairMerkleRoots(); fmt.Sprint(repaired) != "[1]" {
+		t.Fatalf("repair reported %v, want [1]", repaired)
+	}
+	if merkleForest[1].MerkleRoot != want {
+		t.Fatalf("repaired root %.12s, want %.12s", merkleForest[1].MerkleRoot, want)
+	}
+	if root, _, err := store.GetRoot(1); err != nil || root != want {
+		t.Fatalf("persisted root %.12s (%v), want the repaired one", root, err)
+	}
+}
+
+func TestRebalanceAllEvensOutInOnePass(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 4})
+	for i := 0; i < 9; i++ {
+		tip := merkleForest[0].Blocks[len(merkleForest[0].Blocks)-1]
+		if err := acceptBlock(0, MineTestBlockAfter(tip, fmt.Sprint("heavy ", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if before := ForestImbalance(); before != 9 {
+		t.Fatalf("imbalance %d before rebalancing, want 9", before)
+	}
+
+	rebalanceAll()
+	if after := ForestImbalance(); after > 1 {
+		t.Fatalf("imbalance %d after one rebalanceAll, want at most 1", after)
+	}
+	if drifted := auditMerkleRoots(); len(drifted) != 0 {
+		t.Fatalf("shards %v left with stale roots", drifted)
+	}
+	for i, shard := range merkleForest {
+		for _, block := range shard.Blocks {
+			if !isInAMQ(i, block.Hash) {
+				t.Fatalf("shard %d AMQ misses moved block %.12s", i, block.Hash)
+			}
+		}
+	}
+}
+
+func TestCancelledSubmissionLeavesForestUnchanged(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 2})
+	SetDifficulty(16) // 64 bits: mining runs until cancelled
+	root, heights := ForestRoot(), fmt.Sprint(ShardStats())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := AddBlockCtx(ctx, "abandoned", "Validator1")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("cancelled submission: %v, want a context error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("submission took %v to notice cancellation", elapsed)
+	}
+	if ForestRoot() != root || fmt.Sprint(ShardStats()) != heights {
+		t.Fatal("forest changed after a cancelled submission")
+	}
+}
+
+func TestRebalancePlanIsExecutedExactly(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 3})
+	for i := 0; i < 5; i++ {
+		tip := merkleForest[0].Blocks[len(merkleForest[0].Blocks)-1]
+		if err := acceptBlock(0, MineTestBlockAfter(tip, fmt.Sprint("heavy ", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	heavy := blockHashes(merkleForest[0].Blocks)
+	root := ForestRoot()
+
+	plan := PlanRebalance()
+	want := []Move{{From: 0, To: 1, Hash: heavy[5]}, {From: 0, To: 1, Hash: heavy[4]}, {From: 0, To: 2, Hash: heavy[3]}}
+	if fmt.Sprint(plan) != fmt.Sprint(want) {
+		t.Fatalf("plan %v, want %v", plan, want)
+	}
+	if ForestRoot() != root {
+		t.Fatal("planning changed the forest")
+	}
+
+	if err := ExecuteRebalance(plan); err != nil {
+		t.Fatal(err)
+	}
+	for _, move := range plan {
+		if shardContains(move.From, move.Hash) || !shardContains(move.To, move.Hash) {
+			t.Fatalf("block %.12s not moved from shard %d to %d", move.Hash, move.From, move.To)
+		}
+	}
+	if got := fmt.Sprint(len(merkleForest[0].Blocks), len(merkleForest[1].Blocks), len(merkleForest[2].Blocks)); got != "3 3 2" {
+		t.Fatalf("shard sizes %s after the plan, want 3 3 2", got)
+	}
+	if err := ExecuteRebalance(plan); err == nil {
+		t.Fatal("stale plan executed a second time")
+	}
+}
+
+func TestIsolationLevelDecidesWhenShardsSync(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 2})
+	defer useConsensusStubs(fixedVote(true), &countingProofProvider{})()
+	tip := func(i int) string { return merkleForest[i].Blocks[len(merkleForest[i].Blocks)-1].Hash }
+
+	if err := addBlockToShard(0, "strong", "Validator1"); err != nil {
+		t.Fatal(err)
+	}
+	if !shardContains(1, tip(0)) || len(deferredSyncs) != 0 {
+		t.Fatal("Strong shard did not sync as part of the add")
+	}
+
+	merkleForest[0].Isolation = IsolationEventual
+	if err := addBlockToShard(0, "eventual", "Validator2"); err != nil {
+		t.Fatal(err)
+	}
+	if shardContains(1, tip(0)) || len(deferredSyncs) != 1 {
+		t.Fatalf("Eventual shard synced during the add (%d syncs queued)", len(deferredSyncs))
+	}
+	if ran := flushDeferredSyncs(); ran != 1 || !shardContains(1, tip(0)) {
+		t.Fatalf("flush ran %d syncs, block in shard 1: %t", ran, shardContains(1, tip(0)))
+	}
+}
+
+// Runs each benchmark for a single iteration, so a broken one fails go test rather than
+// waiting for someone to run -bench
+func TestBenchmarksRun(t *testing.T) {
+	benchtime := flag.Lookup("test.benchtime")
+	previous := benchtime.Value.String()
+	if err := flag.Set("test.benchtime", "1x"); err != nil {
+		t.Fatal(err)
+	}
+	defer flag.Set("test.benchtime", previous)
+
+	benchmarks := map[string]func(*testing.B){
+		"MineBlock":              BenchmarkMineBlock,
+		"UpdateMerkleRoot":       BenchmarkUpdateMerkleRoot,
+		"GenerateMerkleProof":    BenchmarkGenerateMerkleProof,
+		"MerkleProofOfHashes":    BenchmarkMerkleProofOfHashes,
+		"ValidateMerkleProof":    BenchmarkValidateMerkleProof,
+		"GetAccumulatorSnapshot": BenchmarkGetAccumulatorSnapshot,
+		"AccumulatorOf":          BenchmarkAccumulatorOf,
+		"AMQAdd":                 BenchmarkAMQAdd,
+		"AMQMayContain":          BenchmarkAMQMayContain,
+		"ShardContains":          BenchmarkShardContains,
+		"DBFTConsensus":          BenchmarkDBFTConsensus,
+		"WeightedScoreVote":      BenchmarkWeightedScoreVote,
+	}
+	for name, bench := range benchmarks {
+		if result := testing.Benchmark(bench); result.N == 0 {
+			t.Errorf("Benchmark%s did not run", name)
+		}
+	}
+}
+
+func TestForestRootChangesWithAnyShard(t *testing.T) {
+	defer UseTestDifficulty()()
+	forest := NewTestForest(3, 2)
+	defer InstallTestForest(forest)()
+	root := ForestRoot()
+
+	for i := range forest {
+		changed := cloneForest(forest)
+		last := changed[i].Blocks[len(changed[i].Blocks)-1]
+		changed[i].Blocks = append(changed[i].Blocks, MineTestBlockAfter(last, "one more"))
+		changed[i].MerkleRoot = updateMerkleRoot(i, changed[i].Blocks)
+		merkleForest = changed
+		if ForestRoot() == root {
+			t.Fatalf("appending to shard %d left the forest root unchanged", i)
+		}
+	}
+}
+
+func TestForestRootIsStableForShardOrder(t *testing.T) {
+	defer UseTestDifficulty()()
+	forest := NewTestForest(3, 2)
+	defer InstallTestForest(forest)()
+	root := ForestRoot()
+
+	merkleForest = cloneForest(forest)
+	if ForestRoot() != root {
+		t.Fatal("an identical forest has a different root")
+	}
+	merkleForest[0], merkleForest[2] = merkleForest[2], merkleForest[0]
+	if ForestRoot() == root {
+		t.Fatal("swapping shards 0 and 2 left the root unchanged; it must commit to shard positions")
+	}
+}
+
+func TestForestProofVerifies(t *testing.T) {
+	defer UseTestDifficulty()()
+	defer InstallTestForest(NewTestForest(3, 4))()
+	root := ForestRoot()
+
+	for shard := 0; shard < 3; shard++ {
+		for block := 0; block < 4; block++ {
+			if !VerifyForestProof(GenerateForestProof(shard, block), root) {
+				t.Fatalf("proof for shard %d block %d failed", shard, block)
+			}
+		}
+	}
+	if VerifyForestProof(GenerateForestProof(5, 0), root) {
+		t.Fatal("out-of-range proof verified")
+	}
+}
+
+func TestForestProofWithSwappedShardSiblingFails(t *testing.T) {
+	defer UseTestDifficulty()()
+	defer InstallTestForest(NewTestForest(4, 2))()
+	root := ForestRoot()
+
+	proof := GenerateForestProof(1, 1)
+	if len(proof.ShardProof) == 0 {
+		t.Fatal("shard-level proof is empty")
+	}
+	proof.ShardProof[0] = merkleForest[2].MerkleRoot
+	if VerifyForestProof(proof, root) {
+		t.Fatal("proof with a swapped shard-level sibling verified")
+	}
+}
+
+func TestAncestryOfTipIsTheWholeShard(t *testing.T) {
+	defer UseTestDifficulty()()
+	forest := NewTestForest(1, 5)
+	defer InstallTestForest(forest)()
+
+	ancestors, err := AncestorsOf(0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ancestors) != 5 {
+		t.Fatalf("%d ancestors, want 5", len(ancestors))
+	}
+	for i, block := range ancestors {
+		if block.Hash != forest[0].Blocks[i].Hash {
+			t.Fatalf("ancestor %d is %.12s, want %.12s in genesis-first order", i, block.Hash, forest[0].Blocks[i].Hash)
+		}
+	}
+}
+
+func TestAncestryReportsBrokenLink(t *testing.T) {
+	defer UseTestDifficulty()()
+	forest := NewTestForest(1, 5)
+	forest[0].Blocks[3].PrevHash = strings.Repeat("ab", 32)
+	defer InstallTestForest(forest)()
+
+	if _, err := AncestorsOf(0, 4); err == nil || !strings.Contains(err.Error(), "broken link") {
+		t.Fatalf("AncestorsOf across a broken link: %v", err)
+	}
+	if _, err := AncestorsOf(0, 2); err != nil {
+		t.Fatalf("blocks below the break: %v", err)
+	}
+}
+
+func TestProofOnEmptyShard(t *testing.T) {
+	defer UseTestDifficulty()()
+	defer InstallTestForest(Forest{NewTestShard(0, 3), {}})()
+
+	if proof := generateMerkleProof(1, 0); proof != nil {
+		t.Fatalf("proof on an empty shard = %v, want nil", proof)
+	}
+	if validateMerkleProof(1, 0, nil) {
+		t.Fatal("empty shard validated a proof")
+	}
+	if generateMerkleProof(2, 0) != nil || validateMerkleProof(-1, 0, nil) {
+		t.Fatal("missing shard produced or validated a proof")
+	}
+	if !validateMerkleProof(0, 2, generateMerkleProof(0, 2)) {
+		t.Fatal("populated neighbour stopped proving")
+	}
+}
+
+func TestLowerShardCapacityRebalancesSooner(t *testing.T) {
+	defer UseTestDifficulty()()
+	// Adds into shard 0 before the first rebalance, or -1 if none happens within limit adds
+	addsUntilRebalance := func(c *Chain, limit int) (adds int) {
+		adds = -1
+		c.Do(func() {
+			for i := range merkleForest {
+				merkleForest[i].Isolation = IsolationEventual
+			}
+			for i := 1; i <= limit && adds < 0; i++ {
+				if err := addBlockToShard(0, fmt.Sprint("block ", i), "Validator1"); err != nil {
+					t.Fatal(err)
+				}
+				eventLog.Replay(func(e LoggedEvent) {
+					if e.Kind == EventRebalanced && adds < 0 {
+						adds = i
+					}
+				})
+			}
+		})
+		return adds
+	}
+
+	lowered := NewChain(GenesisConfig{ShardCount: 2})
+	lowered.SetMaxShardCapacity(2)
+	if got := addsUntilRebalance(lowered, 4); got != 2 {
+		t.Fatalf("capacity 2 rebalanced after %d adds, want 2", got)
+	}
+	if got := addsUntilRebalance(NewChain(GenesisConfig{ShardCount: 2}), 4); got != -1 {
+		t.Fatalf("default capacity %d rebalanced after %d adds", defaultMaxShardCapacity, got)
+	}
+	if lowered.capacity != 2 || maxShardCapacity == 2 {
+		t.Fatal("the capacity setting leaked out of its chain")
+	}
+}
+
+func TestRouteKeyRemapsFewKeysWhenAShardJoins(t *testing.T) {
+	const keys = 10000
+	before := []int{0, 1, 2, 3}
+	after := append(append([]int(nil), before...), 4)
+
+	moved := 0
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprint("account-", i)
+		from, to := routeKey(key, before), routeKey(key, after)
+		if from != routeKey(key, []int{3, 1, 0, 2}) {
+			t.Fatalf("%s routed by shard order", key)
+		}
+		if from != to {
+			if to != 4 {
+				t.Fatalf("%s moved from shard %d to %d, not to the new shard", key, from, to)
+			}
+			moved++
+		}
+	}
+	share, want := float64(moved)/keys, 1.0/float64(len(after))
+	if share < want-0.03 || share > want+0.03 {
+		t.Fatalf("%.3f of keys remapped, want about %.3f", share, want)
+	}
+	if routeKey("anything", nil) != -1 {
+		t.Fatal("routed to a shard from an empty set")
+	}
+}
+
+func TestHeaviestShardCountsWorkNotBlocks(t *testing.T) {
+	defer UseTestDifficulty()()
+	long := NewTestShard(0, 6)
+	heavy := NewTestShard(1, 1)
+	for i := 1; i <= 2; i++ {
+		block := MineTestBlockAfter(heavy.Blocks[i-1], fmt.Sprint("hard block ", i))
+		stats, err := mineBlockAt(block, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.Nonce, block.Bits = stats.Nonce, stats.Bits
+		block.seal()
+		heavy.Blocks = append(heavy.Blocks, block)
+	}
+	defer InstallTestForest(Forest{long, heavy})()
+
+	index, work := HeaviestShard()
+	if index != 1 {
+		t.Fatalf("heaviest shard %d, want shard 1 with fewer but harder blocks", index)
+	}
+	if work != chainWork(heavy.Blocks) || work <= chainWork(long.Blocks) {
+		t.Fatalf("reported work %d, shard works %d and %d", work, chainWork(long.Blocks), chainWork(heavy.Blocks))
+	}
+}
+
+func TestProofIsBoundToItsShard(t *testing.T) {
+	defer UseTestDifficulty()()
+	first := NewTestShard(0, 4)
+	second := newShard(1, first.Blocks[0])
+	second.Blocks = append([]Block(nil), first.Blocks...)
+	second.MerkleRoot = updateMerkleRoot(1, second.Blocks)
+	defer InstallTestForest(Forest{first, second})()
+
+	if first.MerkleRoot == second.MerkleRoot {
+		t.Fatal("shards holding the same blocks share a root")
+	}
+	proof := generateMerkleProof(0, 2)
+	if !validateMerkleProof(0, 2, proof) {
+		t.Fatal("proof failed against its own shard")
+	}
+	if validateMerkleProof(1, 2, proof) {
+		t.Fatal("shard 0 proof verified against shard 1")
+	}
+}
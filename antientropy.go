@@ -0,0 +1,130 @@
+package main
+
+import "fmt"
+
+// Per-shard difference between two forests
+type ShardDiff struct {
+	Index        int
+	OnlyInA      []string // block hashes present in a but not in b
+	OnlyInB      []string // block hashes present in b but not in a
+	RootA        string
+	RootB        string
+	RootMismatch bool
+}
+
+// Differences between two forests, the basis for anti-entropy sync
+type ForestDiff struct {
+	Shards []ShardDiff // only shards that differ
+}
+
+// Whether the two forests hold the same blocks under the same roots
+func (d ForestDiff) Empty() bool {
+	return len(d.Shards) == 0
+}
+
+// Compares two forests shard by shard; a shard missing from one side counts as empty
+func DiffForests(a, b *Forest) ForestDiff {
+	var diff ForestDiff
+	n := max(len(*a), len(*b))
+	for i := 0; i < n; i++ {
+		var shardA, shardB Shard
+		if i < len(*a) {
+			shardA = (*a)[i]
+		}
+		if i < len(*b) {
+			shardB = (*b)[i]
+		}
+		shardDiff := ShardDiff{
+			Index:        i,
+			OnlyInA:      missingHashes(shardA.Blocks, shardB.Blocks),
+			OnlyInB:      missingHashes(shardB.Blocks, shardA.Blocks),
+			RootA:        shardA.MerkleRoot,
+			RootB:        shardB.MerkleRoot,
+			RootMismatch: shardA.MerkleRoot != shardB.MerkleRoot,
+		}
+		if shardDiff.RootMismatch || len(shardDiff.OnlyInA) > 0 || len(shardDiff.OnlyInB) > 0 {
+			diff.Shards = append(diff.Shards, shardDiff)
+		}
+	}
+	return diff
+}
+
+// Hashes of blocks in from that are absent in other, in chain order
+func missingHashes(from, other []Block) []string {
+	seen := make(map[string]bool, len(other))
+	for _, block := range other {
+		seen[block.Hash] = true
+	}
+	var missing []string
+	for _, block := range from {
+		if !seen[block.Hash] {
+			missing = append(missing, block.Hash)
+		}
+	}
+	return missing
+}
+
+// Anti-entropy: exchanges missing blocks so both forests converge. When one shard chain extends the
+// other the shorter side catches up; when they have diverged the deterministic resolver picks the
+// winner. Either way the adopted shard must pass validateShard before either side takes it.
+// Only the two views are touched; the ledger and store are left to the caller.
+func Reconcile(local, remote *Forest) error {
+	defer lockForestWrite()()
+	if len(*local) != len(*remote) {
+		return fmt.Errorf("shard count mismatch: local %d, remote %d", len(*local), len(*remote))
+	}
+	for _, shardDiff := range DiffForests(local, remote).Shards {
+		i := shardDiff.Index
+		localBlocks, remoteBlocks := (*local)[i].Blocks, (*remote)[i].Blocks
+		common := commonPrefixLen(localBlocks, remoteBlocks)
+		if common == 0 {
+			return fmt.Errorf("shard %d: no common genesis", i)
+		}
+
+		var winner []Block
+		switch {
+		case common == len(localBlocks):
+			winner = remoteBlocks
+		case common == len(remoteBlocks):
+			winner = localBlocks
+		default:
+			fmt.Printf("Shard %d diverged after height %d.\n", i, common-1)
+			winner = deterministicResolution(localBlocks, remoteBlocks)
+		}
+
+		shard, err := extendShard(i, winner[:common], winner[common:])
+		if err != nil {
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+		shard.Isolation = (*local)[i].Isolation
+		(*local)[i] = shard
+		shard.Blocks = append([]Block(nil), shard.Blocks...)
+		shard.Isolation = (*remote)[i].Isolation
+		(*remote)[i] = shard
+	}
+	return nil
+}
+
+// Number of leading blocks the two chains share
+func commonPrefixLen(a, b []Block) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n].Hash == b[n].Hash {
+		n++
+	}
+	return n
+}
+
+// Fresh shard holding prefix followed by suffix. Checked with validateShard rather than the
+// acceptance validators, since synced and rebalanced blocks need not extend the shard tip.
+func extendShard(shardIndex int, prefix, suffix []Block) (Shard, error) {
+	shard := newShard(shardIndex, prefix[0])
+	for _, block := range append(prefix[1:len(prefix):len(prefix)], suffix...) {
+		shard.Blocks = append(shard.Blocks, block)
+		shard.accumulate(block.Hash)
+	}
+	shard.MerkleRoot = updateMerkleRoot(shardIndex, shard.Blocks)
+	if err := validateShard(shardIndex, shard); err != nil {
+		return Shard{}, err
+	}
+	return shard, nil
+}
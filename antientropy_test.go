@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Copy of forest with shard i extended by blocks carrying data
+func extendedForest(t *testing.T, forest Forest, i int, data ...string) Forest {
+	t.Helper()
+	out := cloneForest(forest)
+	for _, d := range data {
+		tip := out[i].Blocks[len(out[i].Blocks)-1]
+		block := MineTestBlockAfter(tip, d)
+		out[i].Blocks = append(out[i].Blocks, block)
+		out[i].accumulate(block.Hash)
+	}
+	out[i].MerkleRoot = updateMerkleRoot(i, out[i].Blocks)
+	return out
+}
+
+func TestDiffListsExactlyTheDivergentHashes(t *testing.T) {
+	defer UseTestDifficulty()()
+	base := NewTestForest(2, 1)
+	a := extendedForest(t, base, 0, "a1", "a2")
+	b := extendedForest(t, base, 0, "b1")
+
+	diff := DiffForests(&a, &b)
+	if len(diff.Shards) != 1 || diff.Shards[0].Index != 0 {
+		t.Fatalf("diff %+v, want shard 0 only", diff)
+	}
+	d := diff.Shards[0]
+	wantA := fmt.Sprint([]string{a[0].Blocks[1].Hash, a[0].Blocks[2].Hash})
+	wantB := fmt.Sprint([]string{b[0].Blocks[1].Hash})
+	if fmt.Sprint(d.OnlyInA) != wantA || fmt.Sprint(d.OnlyInB) != wantB || !d.RootMismatch {
+		t.Fatalf("shard diff %+v, want a1, a2 only in a and b1 only in b", d)
+	}
+	if same := cloneForest(a); !DiffForests(&a, &same).Empty() {
+		t.Fatal("identical forests differ")
+	}
+}
+
+func TestReconcileConvergesCompatibleForests(t *testing.T) {
+	defer UseTestDifficulty()()
+	base := NewTestForest(3, 2)
+	local := extendedForest(t, base, 0, "local")
+	remote := extendedForest(t, extendedForest(t, base, 1, "remote"), 2, "remote-a", "remote-b")
+
+	if err := Reconcile(&local, &remote); err != nil {
+		t.Fatal(err)
+	}
+	if !DiffForests(&local, &remote).Empty() {
+		t.Fatalf("forests still differ: %+v", DiffForests(&local, &remote))
+	}
+	for i := range local {
+		if local[i].MerkleRoot != remote[i].MerkleRoot {
+			t.Fatalf("shard %d roots differ after reconciling", i)
+		}
+	}
+	if len(local[0].Blocks) != 3 || len(local[2].Blocks) != 4 {
+		t.Fatal("blocks from one side were dropped")
+	}
+	MustValidate(t, local)
+}
+
+func TestReconcileResolvesForksTheSameOnBothSides(t *testing.T) {
+	defer UseTestDifficulty()()
+	base := NewTestForest(1, 1)
+	local := extendedForest(t, base, 0, "local fork")
+	remote := extendedForest(t, base, 0, "remote fork")
+	want := deterministicResolution(local[0].Blocks, remote[0].Blocks)
+
+	if err := Reconcile(&local, &remote); err != nil {
+		t.Fatal(err)
+	}
+	if tipHash(local[0].Blocks) != tipHash(want) || tipHash(remote[0].Blocks) != tipHash(want) {
+		t.Fatal("the fork did not settle on the resolver's choice on both sides")
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+const attackTrials = 2000 // simulated rounds behind each attackProbability estimate
+
+// Estimates the chance that a malicious block passes dBFT when the given fraction of stake is
+// malicious. Each trial marks every validator malicious with that probability. Honest voters
+// reject the block; a malicious voter can only approve when its score allows it, because anyone
+// can recompute the VRF component from its public key and the block hash. Each trial uses a fresh
+// block hash, and the tally goes through the same eligibility filters and quorum policy as
+// dBFTConsensus, with the ZK and MPC checks assumed to pass. Trials use a fixed seed, so a larger
+// fraction never lowers the estimate.
+func attackProbability(maliciousStake float64) float64 {
+	maliciousStake = min(max(maliciousStake, 0), 1)
+	rng := rand.New(rand.NewSource(1))
+	consensusMu.RLock()
+	defer consensusMu.RUnlock()
+
+	var eligible []string
+	weight := make(map[string]float64)
+	for _, id := range validatorsByPriority() {
+		v := validators[id]
+		trust, ok := voterTrust(v)
+		if v.Trust < 0.3 || v.StakeLevel < 1 || !ok {
+			continue
+		}
+		weight[id] = trust
+		if consensusConfig.MaxVotersPerRound > 0 && len(eligible) >= consensusConfig.MaxVotersPerRound {
+			break
+		}
+		eligible = append(eligible, id)
+	}
+	if len(eligible) == 0 {
+		return 0
+	}
+
+	passed := 0
+	for trial := 0; trial < attackTrials; trial++ {
+		blockHash := fmt.Sprintf("attack-%d", trial)
+		var totalTrust, approvedTrust float64
+		var trustValues []float64
+		var approvedStake, rejected int
+		regions := make(map[string]bool)
+		for _, id := range eligible {
+			v := validators[id]
+			approve := false
+			if rng.Float64() < maliciousStake {
+				approve, _ = voteStrategy.Vote(id, v, blockHash)
+			}
+			totalTrust += weight[id]
+			trustValues = append(trustValues, weight[id])
+			if approve {
+				approvedTrust += weight[id] * float64(v.StakeLevel) / 3.0
+				approvedStake += v.StakeLevel
+				regions[v.Location] = true
+			} else {
+				rejected++
+			}
+		}
+
+		if float64(rejected)/float64(len(eligible)) > 0.6 {
+			continue
+		}
+		accepted := approvedTrust/totalTrust >= baseThreshold+(1-average(trustValues))*0.2
+		if quorumPolicy == QuorumTwoThirdsStake {
+			stake := totalStake()
+			accepted = stake > 0 && 3*approvedStake >= 2*stake
+		}
+		if accepted && len(regions) >= consensusConfig.MinRegions {
+			passed++
+		}
+	}
+	return float64(passed) / attackTrials
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestAttackProbabilityIsMonotonic(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1})
+	if p := attackProbability(0); p != 0 {
+		t.Fatalf("no malicious stake passed %.3f of attacks", p)
+	}
+	previous := 0.0
+	for step := 1; step <= 10; step++ {
+		fraction := float64(step) / 10
+		p := attackProbability(fraction)
+		if p < previous {
+			t.Fatalf("attack probability fell from %.3f to %.3f at %.1f malicious stake", previous, p, fraction)
+		}
+		previous = p
+	}
+	if previous <= attackProbability(0.3) {
+		t.Fatalf("fully malicious stake passes no more often (%.3f) than 30%%", previous)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudflare/circl/sign/bls"
+)
+
+// BLS keys live in G1 and signatures in G2, so any number of signatures aggregate into one
+
+// BLS key derived from a keyring seed
+func blsSigningKey(seed []byte) (*bls.PrivateKey[bls.G1], error) {
+	return bls.KeyGen[bls.G1](deriveKey(seed, "validator-bls"), nil, nil)
+}
+
+// BLS key for a validator, derived from its secret keyring seed
+func validatorBLSKey(id string) (*bls.PrivateKey[bls.G1], error) {
+	if _, ok := validators[id]; !ok {
+		return nil, fmt.Errorf("unknown validator %s", id)
+	}
+	seed, err := validatorSeed(id)
+	if err != nil {
+		return nil, err
+	}
+	return blsSigningKey(seed)
+}
+
+// BLS key the validator registered, which QCs are checked against
+func validatorBLSPublicKey(id string) (*bls.PublicKey[bls.G1], error) {
+	v, ok := validators[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown validator %s", id)
+	}
+	if len(v.BLSKey) == 0 {
+		return nil, fmt.Errorf("validator %s registered no BLS key", id)
+	}
+	pub := new(bls.PublicKey[bls.G1])
+	if err := pub.UnmarshalBinary(v.BLSKey); err != nil {
+		return nil, fmt.Errorf("validator %s BLS key: %w", id, err)
+	}
+	return pub, nil
+}
+
+// Signs a block hash with the validator's BLS key
+func blsSignAsValidator(id string, msg []byte) ([]byte, error) {
+	key, err := validatorBLSKey(id)
+	if err != nil {
+		return nil, err
+	}
+	return bls.Sign(key, msg), nil
+}
+
+// Sums individual signatures into one
+func aggregateSignatures(sigs [][]byte) ([]byte, error) {
+	blsSigs := make([]bls.Signature, len(sigs))
+	for i, sig := range sigs {
+		blsSigs[i] = sig
+	}
+	return bls.Aggregate(bls.G1{}, blsSigs)
+}
+
+// Checks that agg is the aggregate of every pubkey's signature over the same message
+func verifyAggregate(agg []byte, pubkeys []*bls.PublicKey[bls.G1], msg []byte) bool {
+	msgs := make([][]byte, len(pubkeys))
+	for i := range msgs {
+		msgs[i] = msg
+	}
+	return bls.VerifyAggregate(pubkeys, msgs, agg)
+}
+
+// Quorum certificate: one aggregated signature over the block hash and a bitmap of who signed.
+// Bit i refers to the i-th validator id in sorted order.
+type QuorumCertificate struct {
+	BlockHash string
+	Bitmap    []byte
+	Signature []byte
+}
+
+func sortedValidatorIDs() []string {
+	var ids []string
+	for id := range validators {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Collects BLS signatures from the given validators and aggregates them into a QC.
+// Signatures are gathered in sorted id order, so the input order of signers does not matter.
+func buildQuorumCertificate(blockHash string, signers []string) (QuorumCertificate, error) {
+	consensusMu.RLock()
+	defer consensusMu.RUnlock()
+	ids := sortedValidatorIDs()
+	qc := QuorumCertificate{BlockHash: blockHash, Bitmap: make([]byte, (len(ids)+7)/8)}
+	signing := make(map[string]bool)
+	for _, id := range signers {
+		signing[id] = true
+	}
+
+	var sigs [][]byte
+	for i, id := range ids {
+		if !signing[id] {
+			continue
+		}
+		sig, err := blsSignAsValidator(id, []byte(blockHash))
+		if err != nil {
+			return qc, err
+		}
+		sigs = append(sigs, sig)
+		qc.Bitmap[i/8] |= 1 << (i % 8)
+		delete(signing, id)
+	}
+	for id := range signing {
+		return qc, fmt.Errorf("unknown validator %s", id)
+	}
+
+	agg, err := aggregateSignatures(sigs)
+	if err != nil {
+		return qc, err
+	}
+	qc.Signature = agg
+	return qc, nil
+}
+
+// Validator ids marked in a QC bitmap
+func (qc QuorumCertificate) Signers() []string {
+	consensusMu.RLock()
+	defer consensusMu.RUnlock()
+	return qc.signers()
+}
+
+func (qc QuorumCertificate) signers() []string {
+	var signers []string
+	for i, id := range sortedValidatorIDs() {
+		if i/8 < len(qc.Bitmap) && qc.Bitmap[i/8]&(1<<(i%8)) != 0 {
+			signers = append(signers, id)
+		}
+	}
+	return signers
+}
+
+// Canonical encoding: block hash, signer ids in sorted order, then the aggregate signature.
+// Two QCs over the same vote set encode identically regardless of the order votes arrived in.
+func (qc QuorumCertificate) Bytes() []byte {
+	signers := qc.Signers()
+	sort.Strings(signers)
+	out := []byte(qc.BlockHash + "|" + strings.Join(signers, ",") + "|")
+	return append(out, qc.Signature...)
+}
+
+// Hash of the canonical encoding, for comparing QCs across nodes
+func (qc QuorumCertificate) Hash() string {
+	sum := sha256.Sum256(qc.Bytes())
+	return hex.EncodeToString(sum[:])
+}
+
+func verifyQuorumCertificate(qc QuorumCertificate) bool {
+	consensusMu.RLock()
+	defer consensusMu.RUnlock()
+	var pubkeys []*bls.PublicKey[bls.G1]
+	for _, id := range qc.signers() {
+		pub, err := validatorBLSPublicKey(id)
+		if err != nil {
+			return false
+		}
+		pubkeys = append(pubkeys, pub)
+	}
+	return len(pubkeys) > 0 && verifyAggregate(qc.Signature, pubkeys, []byte(qc.BlockHash))
+}
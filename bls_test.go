@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cloudflare/circl/sign/bls"
+)
+
+func TestAggregateOfThreeSignatures(t *testing.T) {
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"bls-A": testValidator(0.9, "US"),
+		"bls-B": testValidator(0.9, "EU"),
+		"bls-C": testValidator(0.9, "AS"),
+	})()
+	msg := []byte("block hash")
+
+	var sigs [][]byte
+	var pubkeys []*bls.PublicKey[bls.G1]
+	for _, id := range []string{"bls-A", "bls-B", "bls-C"} {
+		sig, err := blsSignAsValidator(id, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := validatorBLSPublicKey(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigs, pubkeys = append(sigs, sig), append(pubkeys, pub)
+	}
+	agg, err := aggregateSignatures(sigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !verifyAggregate(agg, pubkeys, msg) {
+		t.Fatal("aggregate of three valid signatures failed")
+	}
+	if verifyAggregate(agg, pubkeys[:2], msg) {
+		t.Fatal("aggregate verified with one signer's key left out")
+	}
+	if verifyAggregate(agg, pubkeys, []byte("another hash")) {
+		t.Fatal("aggregate verified over a different message")
+	}
+}
+
+func TestQuorumCertificateIgnoresSignerOrder(t *testing.T) {
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"bls-A": testValidator(0.9, "US"),
+		"bls-B": testValidator(0.9, "EU"),
+		"bls-C": testValidator(0.9, "AS"),
+	})()
+
+	qc, err := buildQuorumCertificate("hash", []string{"bls-C", "bls-A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := buildQuorumCertificate("hash", []string{"bls-A", "bls-C"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verifyQuorumCertificate(qc) || qc.Hash() != again.Hash() {
+		t.Fatal("QCs over the same signers differ or fail to verify")
+	}
+	if got := qc.Signers(); len(got) != 2 || got[0] != "bls-A" || got[1] != "bls-C" {
+		t.Fatalf("bitmap marks %v, want bls-A and bls-C", got)
+	}
+	qc.Bitmap[0] |= 0b010 // claim bls-B signed too
+	if verifyQuorumCertificate(qc) {
+		t.Fatal("QC verified with a signer added to the bitmap")
+	}
+	if _, err := buildQuorumCertificate("hash", []string{"nobody"}); err == nil {
+		t.Fatal("QC built for an unknown validator")
+	}
+}
+
+func TestQuorumCertificateSerializesCanonically(t *testing.T) {
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"bls-A": testValidator(0.9, "US"),
+		"bls-B": testValidator(0.9, "EU"),
+		"bls-C": testValidator(0.9, "AS"),
+		"bls-D": testValidator(0.9, "SA"),
+	})()
+
+	var want []byte
+	for _, order := range [][]string{
+		{"bls-A", "bls-B", "bls-D"},
+		{"bls-D", "bls-B", "bls-A"},
+		{"bls-B", "bls-D", "bls-A"},
+	} {
+		qc, err := buildQuorumCertificate("hash", order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want == nil {
+			want = qc.Bytes()
+		} else if !bytes.Equal(qc.Bytes(), want) {
+			t.Fatalf("votes in order %v serialized differently", order)
+		}
+	}
+	if !bytes.HasPrefix(want, []byte("hash|bls-A,bls-B,bls-D|")) {
+		t.Fatalf("signers not listed in sorted order: %q", want[:24])
+	}
+}
@@ -1,9 +1,10 @@
 package main
 
 import (
-	"crypto/sha256"
 	"fmt"
+	"math"
 	"math/rand"
+	"sort"
 	"time"
 )
 
@@ -20,13 +21,37 @@ const (
 )
 
 var currentState = Consistency // Can be updated dynamically
-var retryCount = 0
+
+func capModeName(mode int) string {
+	switch mode {
+	case Consistency:
+		return "Consistency"
+	case Availability:
+		return "Availability"
+	case PartitionTolerance:
+		return "PartitionTolerance"
+	}
+	return fmt.Sprintf("mode(%d)", mode)
+}
+
+var syncRetry = &RetryController{Base: time.Second, Max: 30 * time.Second}
 
 // Validators pool
 
-// CAPOrchestrator orchestrates CAP tradeoffs.
+// CAPOrchestrator orchestrates CAP tradeoffs. It holds the forest write lock, letting go only
+// while a partition-tolerance retry waits out its backoff.
 func CAPOrchestrator() {
+	unlock := lockForestWrite()
 	predictNetworkPartition()
+	if currentState == PartitionTolerance {
+		delay := syncRetry.NextDelay()
+		fmt.Printf("Retry #%d with backoff %v\n", syncRetry.Attempt(), delay)
+		unlock()
+		time.Sleep(delay)
+		unlock = lockForestWrite()
+	}
+	defer unlock()
+
 	switch currentState {
 	case Consistency:
 		fmt.Println("System is in Consistency mode.")
@@ -47,6 +72,9 @@ func CAPOrchestrator() {
 
 func ensureConsistency() {
 	fmt.Println("Ensuring strong consistency...")
+	if n := flushDeferredSyncs(); n > 0 {
+		fmt.Printf("Flushed %d deferred shard syncs.\n", n)
+	}
 	synchronizeShards()
 	applyVectorClocks()
 }
@@ -65,29 +93,94 @@ func markPendingUpdates() {
 	fmt.Println("Tagging updates as pending for later sync.")
 }
 
+// Runs after CAPOrchestrator has waited out the backoff
 func retrySynchronization() {
-	retryCount++
-	timeout := adaptiveTimeout()
-	fmt.Printf("Retry #%d with timeout %v\n", retryCount, timeout)
-	time.Sleep(timeout)
+	synchronizeShards()
+	if err := validateForest(); err != nil {
+		fmt.Println("Synchronization still failing:", err)
+		return
+	}
+	syncRetry.Success()
+}
+
+// RetryController produces exponential backoff delays with jitter, capped at Max.
+type RetryController struct {
+	Base    time.Duration
+	Max     time.Duration
+	attempt int
+}
+
+// NextDelay returns the wait before the next attempt: half the backoff fixed, half random.
+func (r *RetryController) NextDelay() time.Duration {
+	backoff := r.Max
+	if r.attempt < 32 && r.Base<<r.attempt < r.Max {
+		backoff = r.Base << r.attempt
+	}
+	r.attempt++
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Attempt reports how many retries have been made since the last success.
+func (r *RetryController) Attempt() int {
+	return r.attempt
+}
+
+// Success resets the backoff after a completed synchronization.
+func (r *RetryController) Success() {
+	r.attempt = 0
 }
 
 // --- Adaptive and Advanced Features ---
 
-func adaptiveTimeout() time.Duration {
-	latency := measureNetworkLatency()
-	if latency > 200 {
-		return 5 * time.Second
+// Measures round-trip times to peers
+type LatencyProbe interface {
+	RTTs() []time.Duration // recent samples, in no particular order
+}
+
+// Simulated RTTs drawn uniformly below Max; Rand may be seeded for reproducible runs, nil uses the global source
+type RandomLatencyProbe struct {
+	Samples int
+	Max     time.Duration
+	Rand    *rand.Rand
+}
+
+func (p *RandomLatencyProbe) RTTs() []time.Duration {
+	rtts := make([]time.Duration, p.Samples)
+	for i := range rtts {
+		if p.Rand != nil {
+			rtts[i] = time.Duration(p.Rand.Int63n(int64(p.Max)))
+		} else {
+			rtts[i] = time.Duration(rand.Int63n(int64(p.Max)))
+		}
 	}
-	return 2 * time.Second
+	return rtts
 }
 
+var latencyProbe LatencyProbe = &RandomLatencyProbe{Samples: 5, Max: 300 * time.Millisecond}
+
+// Median of the probe's samples; zero when it has none
+func medianRTT() time.Duration {
+	rtts := latencyProbe.RTTs()
+	if len(rtts) == 0 {
+		return 0
+	}
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	return rtts[len(rtts)/2]
+}
+
+// Simulated latency in milliseconds
 func measureNetworkLatency() int {
-	// Simulated latency in milliseconds
-	return rand.Intn(300)
+	return int(medianRTT().Milliseconds())
 }
 
 func predictNetworkPartition() {
+	previous := currentState
+	defer func() {
+		if currentState != previous {
+			logEvent(EventCAPMode, -1, -1, "", fmt.Sprintf("%s -> %s", capModeName(previous), capModeName(currentState)))
+		}
+	}()
 	if rand.Float64() < 0.3 {
 		currentState = PartitionTolerance
 		fmt.Println("Predicted network partition: switching mode.")
@@ -101,10 +194,14 @@ func predictNetworkPartition() {
 }
 
 // --- Vector Clock Simulation ---
-var vectorClock = map[string]int{
-	"Node1": 0, // Vector clock for Node1
-	"Node2": 0, // Vector clock for Node2
-	"Node3": 0, // Vector clock for Node3
+var vectorClock = newVectorClock()
+
+func newVectorClock() map[string]int {
+	return map[string]int{
+		"Node1": 0, // Vector clock for Node1
+		"Node2": 0, // Vector clock for Node2
+		"Node3": 0, // Vector clock for Node3
+	}
 }
 
 // applyVectorClocks simulates vector clocks for causal consistency.
@@ -138,26 +235,92 @@ func synchronizeClocks(node1, node2 string) {
 	}
 }
 
+// Decides whether a sync round sees conflicting shard views
+type ConflictDetector interface {
+	Detect() bool
+}
+
+// Simulated conflicts at a fixed rate; Rand may be seeded for reproducible runs, nil uses the global source
+type RandomConflictDetector struct {
+	Rate float64
+	Rand *rand.Rand
+}
+
+func (d *RandomConflictDetector) Detect() bool {
+	if d.Rand != nil {
+		return d.Rand.Float64() < d.Rate
+	}
+	return rand.Float64() < d.Rate
+}
+
+var conflictDetector ConflictDetector = &RandomConflictDetector{Rate: 0.2} // 20% simulated conflict rate
+
 func detectConflicts() bool {
-	return rand.Float64() < 0.2 // 20% simulated conflict rate
+	return conflictDetector.Detect()
 }
 
 func resolveConflicts() {
 	if detectConflicts() {
-		fmt.Println("Conflict detected! Applying entropy-based resolution...")
-		probabilisticResolution()
+		fmt.Println("Conflict detected! Applying deterministic resolution...")
+		// The first and last shards stand in for two divergent views of the chain
+		local := merkleForest[0].Blocks
+		remote := merkleForest[len(merkleForest)-1].Blocks
+		deterministicResolution(local, remote)
 	} else {
 		fmt.Println("No conflict detected.")
 	}
 }
 
-func probabilisticResolution() {
-	prob := rand.Float64()
-	if prob < 0.5 {
-		fmt.Println("Resolution: Accept higher entropy state.")
-	} else {
-		fmt.Println("Resolution: Merge divergent states.")
+// Picks the same winner on every node: higher cumulative work, then higher validator entropy,
+// then the lexicographically smaller tip hash
+func deterministicResolution(local, remote []Block) []Block {
+	localWork, remoteWork := chainWork(local), chainWork(remote)
+	if localWork != remoteWork {
+		fmt.Printf("Resolution: Accept higher work state (local %d, remote %d).\n", localWork, remoteWork)
+		if remoteWork > localWork {
+			return remote
+		}
+		return local
 	}
+
+	localEntropy, remoteEntropy := stateEntropy(local), stateEntropy(remote)
+	if localEntropy != remoteEntropy {
+		fmt.Printf("Resolution: Accept higher entropy state (local %.3f, remote %.3f).\n", localEntropy, remoteEntropy)
+		if remoteEntropy > localEntropy {
+			return remote
+		}
+		return local
+	}
+
+	fmt.Println("Resolution: Tie, accept smaller tip hash.")
+	if tipHash(remote) < tipHash(local) {
+		return remote
+	}
+	return local
+}
+
+func tipHash(blocks []Block) string {
+	if len(blocks) == 0 {
+		return ""
+	}
+	return blocks[len(blocks)-1].Hash
+}
+
+// Shannon entropy (bits) of the validator distribution over a chain; higher means less concentrated
+func stateEntropy(blocks []Block) float64 {
+	if len(blocks) == 0 {
+		return 0
+	}
+	counts := make(map[string]int)
+	for _, block := range blocks {
+		counts[block.Validator]++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(len(blocks))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
 }
 
 // --- Byzantine Fault Tolerance (BFT) ---
@@ -184,26 +347,17 @@ func validateBFT(block Block) bool {
 			continue
 		}
 
-		randomInput := fmt.Sprintf("%s:%s", id, block.Hash)
-		randomHash := sha256.Sum256([]byte(randomInput))
-		randomScore := float64(randomHash[0]) / 255.0
-
-		trustFactor := v.Trust * 0.7
-		historyBoost := float64(v.History) * 0.05
-		randomBoost := randomScore * 0.25
-
-		effectiveScore := trustFactor + historyBoost + randomBoost
-		vote := effectiveScore > 0.6
+		vote, _ := voteStrategy.Vote(id, v, block.Hash)
 
 		totalTrust += v.Trust
 		totalVotes++
 
 		if vote {
 			approvedTrust += v.Trust
-			v.History++
+			adjustHistory(v, 1)
 		} else {
 			maliciousVotes++
-			v.History--
+			adjustHistory(v, -1)
 			if v.History < -3 {
 				v.Trust *= 0.9 // Penalize malicious behavior
 			}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryDelaysGrowWithJitterUpToMax(t *testing.T) {
+	r := &RetryController{Base: 10 * time.Millisecond, Max: 200 * time.Millisecond}
+	ceiling := r.Base
+	var delays []time.Duration
+	for attempt := 1; attempt <= 10; attempt++ {
+		d := r.NextDelay()
+		if r.Attempt() != attempt {
+			t.Fatalf("attempt counter %d, want %d", r.Attempt(), attempt)
+		}
+		// Full backoff for this attempt, before jitter trims up to half of it
+		if d > ceiling || d < ceiling/2 {
+			t.Fatalf("attempt %d: delay %v outside [%v, %v]", attempt, d, ceiling/2, ceiling)
+		}
+		delays = append(delays, d)
+		ceiling = min(ceiling*2, r.Max)
+	}
+	if delays[3] <= delays[0] {
+		t.Fatalf("delays did not grow: %v", delays)
+	}
+	for _, d := range delays[len(delays)-3:] {
+		if d > r.Max {
+			t.Fatalf("delay %v above cap %v", d, r.Max)
+		}
+	}
+}
+
+func TestRetryJitterVaries(t *testing.T) {
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 20; i++ {
+		r := &RetryController{Base: time.Second, Max: time.Minute}
+		seen[r.NextDelay()] = true
+	}
+	if len(seen) < 2 {
+		t.Fatal("20 first attempts all waited exactly the same time")
+	}
+}
+
+func TestRetrySuccessResetsAttempts(t *testing.T) {
+	r := &RetryController{Base: 10 * time.Millisecond, Max: time.Second}
+	for i := 0; i < 5; i++ {
+		r.NextDelay()
+	}
+	r.Success()
+	if r.Attempt() != 0 {
+		t.Fatalf("attempt %d after success, want 0", r.Attempt())
+	}
+	if d := r.NextDelay(); d > r.Base {
+		t.Fatalf("first delay after success %v, want at most %v", d, r.Base)
+	}
+}
+
+func TestUniformValidatorsHaveHigherEntropy(t *testing.T) {
+	var uniform, single []Block
+	for i := 0; i < 8; i++ {
+		uniform = append(uniform, Block{BlockHeader: BlockHeader{Validator: fmt.Sprintf("Validator%d", i%4)}})
+		single = append(single, Block{BlockHeader: BlockHeader{Validator: "Validator1"}})
+	}
+	if got := stateEntropy(single); got != 0 {
+		t.Fatalf("single-validator entropy %v, want 0", got)
+	}
+	if got := stateEntropy(uniform); math.Abs(got-2) > 1e-9 {
+		t.Fatalf("four equally used validators give entropy %v, want 2 bits", got)
+	}
+	if stateEntropy(uniform) <= stateEntropy(single) {
+		t.Fatal("uniform chain does not have higher entropy")
+	}
+	if stateEntropy(nil) != 0 {
+		t.Fatal("empty chain has non-zero entropy")
+	}
+}
+
+func TestTwoNodesResolveAConflictAlike(t *testing.T) {
+	defer UseTestDifficulty()()
+	genesis := MineTestBlock("genesis")
+	short := []Block{genesis, MineTestBlockAfter(genesis, "a")}
+	other := []Block{genesis, MineTestBlockAfter(genesis, "b")}
+	long := append(append([]Block(nil), other...), MineTestBlockAfter(other[1], "c"))
+
+	for _, pair := range [][2][]Block{{short, other}, {short, long}, {other, long}} {
+		// Each node sees its own fork as local and the peer's as remote
+		nodeA := deterministicResolution(pair[0], pair[1])
+		nodeB := deterministicResolution(pair[1], pair[0])
+		if tipHash(nodeA) != tipHash(nodeB) {
+			t.Fatalf("nodes chose %.12s and %.12s", tipHash(nodeA), tipHash(nodeB))
+		}
+		for i := 0; i < 5; i++ {
+			if tipHash(deterministicResolution(pair[0], pair[1])) != tipHash(nodeA) {
+				t.Fatal("repeated resolution changed its answer")
+			}
+		}
+	}
+	if got := deterministicResolution(short, long); chainWork(got) < max(chainWork(short), chainWork(long)) {
+		t.Fatal("the fork with more work lost")
+	}
+}
+
+type fixedConflicts bool
+
+func (f fixedConflicts) Detect() bool { return bool(f) }
+
+// Output fn printed to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = previous }()
+	fn()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestInjectedConflictRunsResolution(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 2})
+	previous := conflictDetector
+	defer func() { conflictDetector = previous }()
+
+	conflictDetector = fixedConflicts(true)
+	if out := captureStdout(t, resolveConflicts); !strings.Contains(out, "Conflict detected") || !strings.Contains(out, "Resolution:") {
+		t.Fatalf("always-conflicting detector did not reach resolution:\n%s", out)
+	}
+	conflictDetector = fixedConflicts(false)
+	if out := captureStdout(t, resolveConflicts); !strings.Contains(out, "No conflict detected") {
+		t.Fatalf("never-conflicting detector reported:\n%s", out)
+	}
+
+	seeded := func() ConflictDetector {
+		return &RandomConflictDetector{Rate: 0.5, Rand: rand.New(rand.NewSource(7))}
+	}
+	a, b := seeded(), seeded()
+	for i := 0; i < 20; i++ {
+		if a.Detect() != b.Detect() {
+			t.Fatal("detectors seeded alike diverged")
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Chain is one independent blockchain: the forest, AMQ filters, validator registry and keyring,
+// CAP mode, ledger, mempool, store and subscribers that the package-level functions operate on,
+// along with its configuration (difficulty, consensus and quorum settings, role, validation and
+// mempool hooks, limits). Those functions act on the active chain; Do makes a chain active for
+// the length of a call, so several chains can live in one process. Calls into different chains
+// are serialized, and Do must not be nested. The simulation hooks (proof provider, proposer,
+// latency probe, conflict detector) are shared by the whole process.
+type Chain struct {
+	forest        []Shard
+	shardCount    int
+	capacity      int
+	amqFilters    []AMQFilter
+	validators    map[string]*ValidatorProfile
+	capState      int
+	vectorClock   map[string]int
+	syncRetry     *RetryController
+	ledger        *State
+	mempool       *Mempool
+	proposerLog   *ProposerLog
+	limiter       *RateLimiter
+	store         Store
+	chainID       string
+	hasher        Hasher
+	genesisTime   time.Time
+	shardKeys     map[int][]byte
+	deferredSyncs []deferredSync
+	outbound      map[int][]CrossShardMessage
+	inbound       map[int][]CrossShardMessage
+	delivered     map[string]bool
+	evidence      map[string]bool
+	events        *EventLog
+	partition     *partitionState
+	proofCache    *proofCache
+	keyring       *keyStore
+	subscribers   map[chan BlockEvent]bool
+
+	difficultyBits   int
+	consensusConfig  ConsensusConfig
+	quorumPolicy     QuorumPolicy
+	fastPath         float64
+	mpcConfig        MPCConfig
+	mpcFailurePolicy MPCFailurePolicy
+	voteStrategy     VoteStrategy
+	role             Role
+	blockValidators  []BlockValidator
+	acceptHooks      []AcceptHook
+	finalityDepth    int
+	blockGasLimit    uint64
+	rewardHalving    int
+	inclusionDelay   int
+	trustHalfLife    time.Duration
+	historyMin       int
+	historyMax       int
+	amqAlertRate     float64
+}
+
+// Do holds this exclusively while its chain is swapped in. Work that runs on the active chain
+// outside Do (the producer, HTTP handlers and ProposeAsync) holds it shared, so Do waits for
+// that work to finish and never swaps the globals out from under it.
+var chainMu sync.RWMutex
+
+// Fresh chain built from cfg, with its own in-memory store, keyring and the default validator set
+// unless cfg provides one. It starts from a copy of the active chain's configuration; later
+// changes to either chain's configuration leave the other alone.
+func NewChain(cfg GenesisConfig) *Chain {
+	c := activeChain()
+	c.blockValidators = slices.Clone(c.blockValidators)
+	c.acceptHooks = slices.Clone(c.acceptHooks)
+	c.forest = nil
+	c.amqFilters = nil
+	c.capacity = defaultMaxShardCapacity
+	c.capState = Consistency
+	c.vectorClock = newVectorClock()
+	c.syncRetry = &RetryController{Base: time.Second, Max: 30 * time.Second}
+	c.mempool = &Mempool{}
+	c.proposerLog = &ProposerLog{entries: make(map[[2]int]ProposerEntry)}
+	c.limiter = newRateLimiter(RateLimit{Rate: 1, Burst: 5})
+	c.store = newMemoryStore()
+	c.shardKeys = make(map[int][]byte)
+	c.deferredSyncs = nil
+	c.outbound = make(map[int][]CrossShardMessage)
+	c.inbound = make(map[int][]CrossShardMessage)
+	c.delivered = make(map[string]bool)
+	c.evidence = make(map[string]bool)
+	c.events = &EventLog{}
+	c.partition = nil
+	c.proofCache = newProofCache(defaultProofCacheSize)
+	c.keyring = newKeyStore()
+	c.subscribers = make(map[chan BlockEvent]bool)
+	c.Do(func() {
+		validators = defaultValidators() // keys from this chain's keyring
+		initForest(cfg)
+		if err := persistForest(); err != nil {
+			panic(err)
+		}
+	})
+	return &c
+}
+
+// Runs fn with this chain active, then restores whichever state was active before
+func (c *Chain) Do(fn func()) {
+	chainMu.Lock()
+	defer chainMu.Unlock()
+
+	previous := activeChain()
+	c.activate()
+	defer func() {
+		*c = activeChain()
+		previous.activate()
+	}()
+	fn()
+}
+
+// Adds a block to this chain's least-loaded shard
+func (c *Chain) AddBlock(data, validator string) error {
+	var err error
+	c.Do(func() { err = addBlockToShards(data, validator) })
+	return err
+}
+
+// Sets the shard capacity used by this chain's subsequent adds
+func (c *Chain) SetMaxShardCapacity(n int) {
+	c.Do(func() { SetMaxShardCapacity(n) })
+}
+
+// Immutable view of this chain's forest
+func (c *Chain) Snapshot() ForestSnapshot {
+	var snap ForestSnapshot
+	c.Do(func() { snap = Snapshot() })
+	return snap
+}
+
+// Captures the package-level chain state
+func activeChain() Chain {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	return Chain{
+		forest:        merkleForest,
+		shardCount:    shardCount,
+		capacity:      maxShardCapacity,
+		amqFilters:    amqFilters,
+		validators:    validators,
+		capState:      currentState,
+		vectorClock:   vectorClock,
+		syncRetry:     syncRetry,
+		ledger:        ledger,
+		mempool:       mempool,
+		proposerLog:   proposerLog,
+		limiter:       submissionLimiter,
+		store:         store,
+		chainID:       chainID,
+		hasher:        blockHasher,
+		genesisTime:   genesisTime,
+		shardKeys:     shardKeys,
+		deferredSyncs: deferredSyncs,
+		outbound:      outboundMessages,
+		inbound:       inboundMessages,
+		delivered:     deliveredMessages,
+		evidence:      appliedEvidence,
+		events:        eventLog,
+		partition:     partition,
+		proofCache:    merkleProofCache,
+		keyring:       keyring,
+		subscribers:   subscribers,
+
+		difficultyBits:   difficultyBits,
+		consensusConfig:  consensusConfig,
+		quorumPolicy:     quorumPolicy,
+		fastPath:         fastPathThreshold,
+		mpcConfig:        mpcConfig,
+		mpcFailurePolicy: mpcFailurePolicy,
+		voteStrategy:     voteStrategy,
+		role:             nodeRole,
+		blockValidators:  blockValidators,
+		acceptHooks:      acceptHooks,
+		finalityDepth:    finalityDepth,
+		blockGasLimit:    blockGasLimit,
+		rewardHalving:    rewardHalvingInterval,
+		inclusionDelay:   inclusionDelay,
+		trustHalfLife:    trustHalfLife,
+		historyMin:       historyMin,
+		historyMax:       historyMax,
+		amqAlertRate:     amqAlertRate,
+	}
+}
+
+// Installs c as the package-level chain state
+func (c Chain) activate() {
+	merkleForest = c.forest
+	shardCount = c.shardCount
+	maxShardCapacity = c.capacity
+	amqFilters = c.amqFilters
+	validators = c.validators
+	currentState = c.capState
+	vectorClock = c.vectorClock
+	syncRetry = c.syncRetry
+	ledger = c.ledger
+	mempool = c.mempool
+	proposerLog = c.proposerLog
+	submissionLimiter = c.limiter
+	store = c.store
+	chainID = c.chainID
+	blockHasher = c.hasher
+	genesisTime = c.genesisTime
+	shardKeys = c.shardKeys
+	deferredSyncs = c.deferredSyncs
+	outboundMessages = c.outbound
+	inboundMessages = c.inbound
+	deliveredMessages = c.delivered
+	appliedEvidence = c.evidence
+	eventLog = c.events
+	partition = c.partition
+	merkleProofCache = c.proofCache
+	keyring = c.keyring
+	subscribersMu.Lock()
+	subscribers = c.subscribers
+	subscribersMu.Unlock()
+
+	difficultyBits = c.difficultyBits
+	consensusConfig = c.consensusConfig
+	quorumPolicy = c.quorumPolicy
+	fastPathThreshold = c.fastPath
+	mpcConfig = c.mpcConfig
+	mpcFailurePolicy = c.mpcFailurePolicy
+	voteStrategy = c.voteStrategy
+	nodeRole = c.role
+	blockValidators = c.blockValidators
+	acceptHooks = c.acceptHooks
+	finalityDepth = c.finalityDepth
+	blockGasLimit = c.blockGasLimit
+	rewardHalvingInterval = c.rewardHalving
+	inclusionDelay = c.inclusionDelay
+	trustHalfLife = c.trustHalfLife
+	historyMin, historyMax = c.historyMin, c.historyMax
+	amqAlertRate = c.amqAlertRate
+}
@@ -0,0 +1,81 @@
+package main
+
+import "testing"
+
+func TestChainsAreIndependent(t *testing.T) {
+	defer UseTestDifficulty()()
+	before := activeChain()
+	a := NewChain(GenesisConfig{ShardCount: 2, ChainID: "chain-a"})
+	b := NewChain(GenesisConfig{ShardCount: 2, ChainID: "chain-b"})
+
+	for i := 0; i < 3; i++ {
+		if err := a.AddBlock("only in a", "Validator1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.AddBlock("only in b", "Validator1"); err != nil {
+		t.Fatal(err)
+	}
+	b.Do(func() { validators["Validator2"].Trust = 0 })
+
+	count := func(c *Chain, data string) (n int) {
+		c.Do(func() {
+			for _, shard := range merkleForest {
+				for _, block := range shard.Blocks {
+					if block.Data == data {
+						n++
+					}
+				}
+			}
+		})
+		return n
+	}
+	if count(a, "only in a") == 0 || count(a, "only in b") != 0 {
+		t.Fatal("chain a holds the wrong blocks")
+	}
+	if count(b, "only in b") == 0 || count(b, "only in a") != 0 {
+		t.Fatal("chain b holds the wrong blocks")
+	}
+	a.Do(func() {
+		if validators["Validator2"].Trust == 0 {
+			t.Error("a change to b's validators showed up in a")
+		}
+	})
+	if activeChain().chainID != before.chainID || len(merkleForest) != len(before.forest) {
+		t.Fatal("Do left another chain's state installed")
+	}
+}
+
+func TestChainConfigurationIsPerChain(t *testing.T) {
+	defer UseTestDifficulty()()
+	a := NewChain(GenesisConfig{ShardCount: 1, ChainID: "chain-a"})
+	b := NewChain(GenesisConfig{ShardCount: 1, ChainID: "chain-b"})
+	before := activeChain()
+
+	a.Do(func() {
+		SetDifficulty(2)
+		consensusConfig.MaxRounds = 7
+		quorumPolicy = QuorumTwoThirdsStake
+		SetRole(RoleReplica)
+		RegisterAcceptHook(func(int, Block) {})
+	})
+	b.Do(func() {
+		if difficultyBits != before.difficultyBits || consensusConfig.MaxRounds != before.consensusConfig.MaxRounds {
+			t.Errorf("b has difficulty %d and %d rounds, changed along with a", difficultyBits, consensusConfig.MaxRounds)
+		}
+		if quorumPolicy != before.quorumPolicy || nodeRole != RoleValidator || len(acceptHooks) != len(before.acceptHooks) {
+			t.Error("a's quorum policy, role or hooks showed up in b")
+		}
+	})
+	if err := b.AddBlock("mined at b's difficulty", "Validator1"); err != nil {
+		t.Fatal(err)
+	}
+	a.Do(func() {
+		if difficultyBits != 8 || consensusConfig.MaxRounds != 7 || nodeRole != RoleReplica {
+			t.Errorf("a lost its configuration: difficulty %d, %d rounds, role %v", difficultyBits, consensusConfig.MaxRounds, nodeRole)
+		}
+	})
+	if difficultyBits != before.difficultyBits || consensusConfig != before.consensusConfig || nodeRole != before.role {
+		t.Fatal("a chain's configuration leaked into the active one")
+	}
+}
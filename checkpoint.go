@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Shard checkpoint signed by the committee, so a light client can trust it without replaying history
+type SignedCheckpoint struct {
+	ShardIndex int
+	Height     int
+	Root       string
+	Signatures map[string][]byte
+}
+
+// Bytes each validator signs
+func (cp SignedCheckpoint) message() []byte {
+	return []byte(fmt.Sprintf("checkpoint:%d:%d:%s", cp.ShardIndex, cp.Height, cp.Root))
+}
+
+// Checkpoints a shard's current tip and collects signatures from every validator fit to vote
+func gatherCheckpointSignatures(shardIndex int) SignedCheckpoint {
+	shard := merkleForest[shardIndex]
+	cp := SignedCheckpoint{
+		ShardIndex: shardIndex,
+		Height:     shardHeight(shard),
+		Root:       shard.MerkleRoot,
+		Signatures: make(map[string][]byte),
+	}
+	consensusMu.RLock()
+	defer consensusMu.RUnlock()
+	for id, v := range validators {
+		if v.Trust < 0.3 || v.StakeLevel < 1 || time.Since(v.LastPing) > authTimeout {
+			continue
+		}
+		if sig, ok := signAsValidator(id, cp.message()); ok {
+			cp.Signatures[id] = sig
+		}
+	}
+	return cp
+}
+
+// Accepts a checkpoint once valid signatures cover more than 2/3 of total stake
+func VerifyCheckpoint(cp SignedCheckpoint) error {
+	consensusMu.RLock()
+	defer consensusMu.RUnlock()
+	total := totalStake()
+	if total == 0 {
+		return fmt.Errorf("no stake registered")
+	}
+	signed := 0
+	for id, sig := range cp.Signatures {
+		if !verifyValidatorSignature(id, cp.message(), sig) {
+			return fmt.Errorf("invalid signature from %s", id)
+		}
+		signed += validators[id].StakeLevel
+	}
+	if 3*signed <= 2*total {
+		return fmt.Errorf("insufficient quorum: %d of %d stake signed", signed, total)
+	}
+	return nil
+}
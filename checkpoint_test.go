@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestCheckpointNeedsSupermajority(t *testing.T) {
+	defer UseTestDifficulty()()
+	defer InstallTestForest(NewTestForest(1, 3))()
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"cp-A": testValidator(0.9, "US"),
+		"cp-B": testValidator(0.9, "EU"),
+		"cp-C": testValidator(0.9, "AS"),
+		"cp-D": testValidator(0.9, "SA"),
+	})()
+
+	cp := gatherCheckpointSignatures(0)
+	if len(cp.Signatures) != 4 || cp.Root != merkleForest[0].MerkleRoot || cp.Height != 2 {
+		t.Fatalf("checkpoint %+v, want shard 0 at height 2 signed by all four", cp)
+	}
+	if err := VerifyCheckpoint(cp); err != nil {
+		t.Fatalf("fully signed checkpoint: %v", err)
+	}
+
+	delete(cp.Signatures, "cp-C")
+	if err := VerifyCheckpoint(cp); err != nil {
+		t.Fatalf("three of four signatures: %v", err)
+	}
+	delete(cp.Signatures, "cp-D")
+	if err := VerifyCheckpoint(cp); err == nil {
+		t.Fatal("half the stake verified")
+	}
+}
+
+func TestCheckpointSignaturesCoverTheRoot(t *testing.T) {
+	defer UseTestDifficulty()()
+	defer InstallTestForest(NewTestForest(1, 2))()
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"cp-A": testValidator(0.9, "US"),
+		"cp-B": testValidator(0.9, "EU"),
+	})()
+
+	cp := gatherCheckpointSignatures(0)
+	cp.Root = merkleForest[0].Blocks[0].Hash
+	if err := VerifyCheckpoint(cp); err == nil {
+		t.Fatal("signatures over another root verified")
+	}
+}
@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"math"
 	"math/rand"
+	"sort"
+	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Extended validator profile
@@ -15,17 +23,33 @@ type ValidatorProfile struct {
 	PublicKey  string
 	StakeLevel int
 	LastPing   time.Time
+	VerifyKey  []byte // ed25519 public key signed votes and checkpoints are checked against
+	BLSKey     []byte // compressed BLS public key quorum certificates are checked against
+
+	trustDecayedAt time.Time // when decayTrust last ran
 }
 
-var validators = map[string]*ValidatorProfile{
-	"Validator1": {Trust: 0.9, History: 3, Location: "US", PublicKey: "pk1", StakeLevel: 3, LastPing: time.Now()},
-	"Validator2": {Trust: 0.7, History: 2, Location: "EU", PublicKey: "pk2", StakeLevel: 2, LastPing: time.Now()},
-	"Validator3": {Trust: 0.4, History: 1, Location: "AS", PublicKey: "pk3", StakeLevel: 1, LastPing: time.Now().Add(-2 * time.Minute)},
-	"Validator4": {Trust: 0.2, History: 0, Location: "AF", PublicKey: "pk4", StakeLevel: 0, LastPing: time.Now()},
+var validators = defaultValidators()
+
+// Built-in validator set used when the genesis config doesn't provide one
+func defaultValidators() map[string]*ValidatorProfile {
+	return withLocalKeys(map[string]*ValidatorProfile{
+		"Validator1": {Trust: 0.9, History: 3, Location: "US", PublicKey: "pk1", StakeLevel: 3, LastPing: time.Now()},
+		"Validator2": {Trust: 0.7, History: 2, Location: "EU", PublicKey: "pk2", StakeLevel: 2, LastPing: time.Now()},
+		"Validator3": {Trust: 0.4, History: 1, Location: "AS", PublicKey: "pk3", StakeLevel: 1, LastPing: time.Now().Add(-2 * time.Minute)},
+		"Validator4": {Trust: 0.2, History: 0, Location: "AF", PublicKey: "pk4", StakeLevel: 0, LastPing: time.Now()},
+	})
 }
 
 const baseThreshold = 0.5
+
+// When every voter's trust is at least this and all approve, dBFT accepts without the MPC round
+var fastPathThreshold = 0.85
+
 const authTimeout = 90 * time.Second
+const defaultDifficulty = 4 // leading zero hex characters
+
+var difficultyBits = 4 * defaultDifficulty // leading zero bits required in a block hash
 
 // External proof interface
 type ExternalProofProvider interface {
@@ -45,20 +69,139 @@ func (p *SimulatedProofProvider) RunMPC(nodeCount int) bool {
 
 var proofProvider ExternalProofProvider = &SimulatedProofProvider{}
 
-func mineBlock(block Block) int {
-	const difficulty = 4
-	var nonce int
-	for {
-		block.Nonce = nonce
-		hash := calculateHash(block)
-		if isValidHash(hash, difficulty) {
-			return nonce
+// Decides one validator's ballot on a block, returning the vote and the score behind it
+type VoteStrategy interface {
+	Vote(id string, v *ValidatorProfile, blockHash string) (bool, float64)
+}
+
+// Default scoring: trust*0.7 + history*0.05 + VRF randomness*0.25, approving above 0.6.
+// History is clamped to historyMin..historyMax, capping the score at 0.95 + historyMax*0.05.
+// The randomness is keyed by the validator's id and the block hash.
+type WeightedScoreStrategy struct{}
+
+func (WeightedScoreStrategy) Vote(id string, v *ValidatorProfile, blockHash string) (bool, float64) {
+	randomHash := sha256.Sum256([]byte(fmt.Sprintf("%s:%s", id, blockHash)))
+	randomScore := float64(randomHash[0]) / 255.0
+
+	trustFactor := v.Trust * 0.7
+	historyBoost := float64(clampHistory(v.History)) * 0.05
+	randomBoost := randomScore * 0.25
+
+	score := trustFactor + historyBoost + randomBoost
+	return score > 0.6, score
+}
+
+var voteStrategy VoteStrategy = WeightedScoreStrategy{}
+
+// Guards the validator registry: the validators map and the profiles in it. Consensus runs hold
+// it exclusively, since they update History and Trust as they tally; the registry's exported
+// entry points take it as well, and the helpers they call expect it already held.
+var consensusMu sync.RWMutex
+
+// What consensus does when the MPC step fails
+type MPCFailurePolicy int
+
+const (
+	MPCFailClosed MPCFailurePolicy = iota // reject the block
+	MPCFailOpen                           // accept it, flagged MPC-unverified for later audit
+)
+
+var mpcFailurePolicy = MPCFailClosed
+
+// How dBFT decides whether the approvals are enough
+type QuorumPolicy int
+
+const (
+	QuorumTrustRatio     QuorumPolicy = iota // stake-weighted trust ratio against the dynamic threshold
+	QuorumTwoThirdsStake                     // PBFT-style: approvers hold at least 2/3 of all registered stake
+)
+
+var quorumPolicy = QuorumTrustRatio
+
+// Global difficulty override in hex characters (e.g. 1 for fast tests); values below 1 restore the default
+func SetDifficulty(d int) {
+	if d < 1 {
+		d = defaultDifficulty
+	}
+	difficultyBits = 4 * d
+}
+
+// Highest nonce tried before mining gives up, so an unreachable difficulty fails instead of hanging
+var maxNonce = math.MaxInt
+
+var errNonceExhausted = errors.New("nonce exhausted")
+
+// Overrides the mining bound; n < 1 restores the default (unbounded in practice)
+func SetMaxNonce(n int) {
+	if n < 1 {
+		n = math.MaxInt
+	}
+	maxNonce = n
+}
+
+// Outcome of a PoW search: winning nonce, hashes tried and time spent
+type MiningStats struct {
+	Nonce    int
+	Bits     int // difficulty mined at, to record in the header
+	Tries    int
+	Duration time.Duration
+}
+
+func mineBlock(block Block) (MiningStats, error) {
+	return mineBlockAt(block, difficultyBits)
+}
+
+// Mining at an explicit difficulty, independent of the global setting
+func mineBlockAt(block Block, bits int) (MiningStats, error) {
+	return mineBlockCtx(context.Background(), block, bits)
+}
+
+const ctxCheckInterval = 1024 // nonces tried between context checks
+
+// Mining that gives up with ctx.Err() once ctx is done, or errNonceExhausted past maxNonce
+func mineBlockCtx(ctx context.Context, block Block, bits int) (MiningStats, error) {
+	chain := proposalChain()
+	chain.Bits = bits
+	return mineProposal(ctx, block, chain)
+}
+
+// Mining against a snapshot of the chain rather than the live settings
+func mineProposal(ctx context.Context, block Block, chain ProposalChain) (stats MiningStats, err error) {
+	bits, maxNonce := chain.Bits, chain.MaxNonce
+	_, span := tracer.Start(ctx, "mineBlock", trace.WithAttributes(
+		attribute.Int("block.index", block.Index),
+		attribute.Int("difficulty.bits", bits),
+	))
+	defer func() {
+		span.SetAttributes(attribute.Int("mining.tries", stats.Tries))
+		endSpan(span, err)
+	}()
+
+	start := time.Now()
+	header := block.sealedHeader()
+	header.Bits = bits
+	for nonce := 0; nonce <= maxNonce; nonce++ {
+		if nonce%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return MiningStats{Tries: nonce, Duration: time.Since(start)}, ctx.Err()
+		}
+		header.Nonce = nonce
+		hash := hashHeaderFor(header, chain.ID, chain.Hasher)
+		if hasLeadingZeroBits(hash, bits) {
+			return MiningStats{Nonce: nonce, Bits: bits, Tries: nonce + 1, Duration: time.Since(start)}, nil
 		}
-		nonce++
 	}
+	stats = MiningStats{Tries: maxNonce + 1, Duration: time.Since(start)}
+	return stats, fmt.Errorf("%w: no hash with %d leading zero bits in %d tries", errNonceExhausted, bits, stats.Tries)
+}
+
+// Expected hashes to find a valid nonce: each leading zero bit is a 1-in-2 chance
+func expectedTries(bits int) float64 {
+	return math.Pow(2, float64(bits))
 }
 
-func dBFTConsensus(block Block) bool {
+func dBFTConsensus(ctx context.Context, block *Block) (accepted bool) {
+	consensusMu.Lock()
+	defer consensusMu.Unlock()
 	rand.Seed(time.Now().UnixNano())
 	fmt.Println("Hybrid Consensus: dBFT + PoW randomness")
 
@@ -66,48 +209,72 @@ func dBFTConsensus(block Block) bool {
 	var trustValues []float64
 	var maliciousVotes int
 	var totalVotes int
+	var approvedStake int
+	var votes []ValidatorVote
+	allHighTrust := true
+	approvedRegions := make(map[string]bool)
 
-	for id, v := range validators {
+	_, span := tracer.Start(ctx, "dBFTConsensus", trace.WithAttributes(attribute.Int("block.index", block.Index)))
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("votes.total", totalVotes),
+			attribute.Int("votes.approved", totalVotes-maliciousVotes),
+			attribute.Bool("consensus.accepted", accepted),
+		)
+		span.End()
+		logEvent(EventConsensus, -1, -1, block.Hash, fmt.Sprintf("accepted=%t votes=%d/%d", accepted, totalVotes-maliciousVotes, totalVotes))
+	}()
+
+	now := time.Now()
+	for _, v := range validators {
+		decayTrust(v, now)
+	}
+
+	for _, id := range validatorsByPriority() {
+		v := validators[id]
 		if v.Trust < 0.3 || v.StakeLevel < 1 {
 			fmt.Printf("%s skipped (low trust/stake)\n", id)
 			continue
 		}
-		if time.Since(v.LastPing) > authTimeout {
+		trust, ok := voterTrust(v)
+		if !ok {
 			fmt.Printf("%s failed auth (stale ping)\n", id)
 			continue
 		}
+		if trust < v.Trust {
+			fmt.Printf("%s stale, counted at %.2f trust (availability mode)\n", id, trust)
+		}
 		if !proofProvider.VerifyZK(v.PublicKey) {
 			fmt.Printf("%s failed cryptographic check\n", id)
 			continue
 		}
+		if consensusConfig.MaxVotersPerRound > 0 && totalVotes >= consensusConfig.MaxVotersPerRound {
+			fmt.Printf("%s deferred (voter cap %d reached)\n", id, consensusConfig.MaxVotersPerRound)
+			continue
+		}
 
-		randomInput := fmt.Sprintf("%s:%s", id, block.Hash)
-		randomHash := sha256.Sum256([]byte(randomInput))
-		randomScore := float64(randomHash[0]) / 255.0
-		vrfOutput := fmt.Sprintf("%x", randomHash)
-
-		trustFactor := v.Trust * 0.7
-		historyBoost := float64(v.History) * 0.05
-		randomBoost := randomScore * 0.25
-
-		effectiveScore := trustFactor + historyBoost + randomBoost
-		vote := effectiveScore > 0.6
+		vote, effectiveScore := voteStrategy.Vote(id, v, block.Hash)
 
 		stakeWeight := float64(v.StakeLevel) / 3.0
-		weightedTrust := v.Trust * stakeWeight
+		weightedTrust := trust * stakeWeight
 
-		totalTrust += v.Trust
-		trustValues = append(trustValues, v.Trust)
+		totalTrust += trust
+		trustValues = append(trustValues, trust)
+		allHighTrust = allHighTrust && trust >= fastPathThreshold
 		totalVotes++
+		votes = append(votes, ValidatorVote{Validator: id, Trust: trust, Score: effectiveScore, Weight: weightedTrust, Approved: vote})
 
 		if vote {
-			fmt.Printf("%s voted ✅ (score: %.2f, vrf: %s)\n", id, effectiveScore, vrfOutput[:8])
+			fmt.Printf("%s voted ✅ (score: %.2f)\n", id, effectiveScore)
 			approvedTrust += weightedTrust
-			v.History++
+			approvedStake += v.StakeLevel
+			approvedRegions[v.Location] = true
+			adjustHistory(v, 1)
+			recoverTrust(v)
 		} else {
-			fmt.Printf("%s voted ❌ (score: %.2f, vrf: %s) ❌ REJECTED\n", id, effectiveScore, vrfOutput[:8])
+			fmt.Printf("%s voted ❌ (score: %.2f) ❌ REJECTED\n", id, effectiveScore)
 			maliciousVotes++
-			v.History--
+			adjustHistory(v, -1)
 			if v.History < -3 {
 				v.Trust *= 0.9
 			}
@@ -122,27 +289,165 @@ func dBFTConsensus(block Block) bool {
 	avgTrust := average(trustValues)
 	dynamicThreshold := baseThreshold + (1-avgTrust)*0.2
 	ratio := approvedTrust / totalTrust
+	accepted = ratio >= dynamicThreshold
+	if quorumPolicy == QuorumTwoThirdsStake {
+		stake := totalStake()
+		dynamicThreshold = 2.0 / 3.0
+		ratio = float64(approvedStake) / float64(max(stake, 1))
+		accepted = stake > 0 && 3*approvedStake >= 2*stake
+	}
 
 	fmt.Printf("Approval Ratio: %.2f | Required: %.2f\n", ratio, dynamicThreshold)
 
+	if accepted && len(approvedRegions) < consensusConfig.MinRegions {
+		fmt.Printf("Consensus failed: approvers span %d regions, %d required.\n", len(approvedRegions), consensusConfig.MinRegions)
+		accepted = false
+	}
+
 	if totalVotes > 0 && float64(maliciousVotes)/float64(totalVotes) > 0.6 {
 		fmt.Println("Consensus failed: majority of validators likely malicious.")
 		return false
 	}
 
-	if proofProvider.RunMPC(totalVotes) {
+	fastPath := accepted && allHighTrust && maliciousVotes == 0
+	block.FastPath = fastPath
+	if fastPath {
+		fmt.Println("Fast path: unanimous high-trust approval, skipping MPC.")
+		recordConsensus(block, votes, totalTrust, approvedTrust, dynamicThreshold, ratio, true)
+		return true
+	}
+
+	mpcVerified := proofProvider.RunMPC(totalVotes)
+	if mpcVerified {
 		fmt.Println("MPC agreement confirmed.")
+	} else if mpcFailurePolicy == MPCFailOpen {
+		fmt.Println("MPC failure: continuing fail-open, block marked MPC-unverified.")
 	} else {
 		fmt.Println("MPC failure.")
 		return false
 	}
+	block.MPCVerified = mpcVerified
 
-	return ratio >= dynamicThreshold
+	if accepted {
+		recordConsensus(block, votes, totalTrust, approvedTrust, dynamicThreshold, ratio, false)
+	}
+	return accepted
+}
+
+// Attaches the audit record of an accepted vote to the block
+func recordConsensus(block *Block, votes []ValidatorVote, totalTrust, approvedTrust, threshold, ratio float64, fastPath bool) {
+	sort.Slice(votes, func(i, j int) bool { return votes[i].Validator < votes[j].Validator })
+	block.Consensus = &ConsensusRecord{
+		Votes:         votes,
+		TotalTrust:    totalTrust,
+		ApprovedTrust: approvedTrust,
+		Threshold:     threshold,
+		Ratio:         ratio,
+		FastPath:      fastPath,
+	}
+}
+
+// One validator's ballot as counted by dBFT
+type ValidatorVote struct {
+	Validator string
+	Trust     float64 // trust at the time of the vote
+	Score     float64
+	Weight    float64 // stake-weighted trust credited on approval
+	Approved  bool
+}
+
+// Why consensus accepted a block: the tally, the dynamic threshold and every counted vote
+type ConsensusRecord struct {
+	Votes         []ValidatorVote
+	TotalTrust    float64
+	ApprovedTrust float64
+	Threshold     float64
+	Ratio         float64
+	FastPath      bool // accepted unanimously by high-trust voters, without the MPC round
+}
+
+// Audit record stored with the block at the given position in a shard
+func ConsensusRecordFor(shardIndex, blockIndex int) (*ConsensusRecord, bool) {
+	if shardIndex < 0 || shardIndex >= len(merkleForest) {
+		return nil, false
+	}
+	blocks := merkleForest[shardIndex].Blocks
+	if blockIndex < 0 || blockIndex >= len(blocks) || blocks[blockIndex].Consensus == nil {
+		return nil, false
+	}
+	return blocks[blockIndex].Consensus, true
 }
 
-// Simulated MPC agreement
+// Polling order: highest trust first, then highest stake, then id
+func validatorsByPriority() []string {
+	ids := sortedValidatorIDs()
+	sort.SliceStable(ids, func(i, j int) bool {
+		a, b := validators[ids[i]], validators[ids[j]]
+		if a.Trust != b.Trust {
+			return a.Trust > b.Trust
+		}
+		return a.StakeLevel > b.StakeLevel
+	})
+	return ids
+}
+
+// Shape of the simulated MPC round: t-of-n participants must each come through
+type MPCConfig struct {
+	N                      int     // participants; 0 uses the number of voters
+	Threshold              int     // participants that must succeed; 0 means a 2/3 supermajority
+	ParticipantReliability float64 // chance each participant completes its share
+}
+
+var mpcConfig = MPCConfig{ParticipantReliability: 0.99}
+
+// Participants and threshold for a round with the given number of voters
+func (c MPCConfig) resolve(voters int) (n, t int) {
+	n = c.N
+	if n <= 0 {
+		n = voters
+	}
+	t = c.Threshold
+	if t <= 0 {
+		t = (2*n + 2) / 3
+	}
+	return n, t
+}
+
+// Exact chance that at least t of n independent participants succeed
+func (c MPCConfig) SuccessProbability(voters int) float64 {
+	n, t := c.resolve(voters)
+	if n <= 0 || t > n {
+		return 0
+	}
+	p := c.ParticipantReliability
+	total := 0.0
+	for k := t; k <= n; k++ {
+		total += binomial(n, k) * math.Pow(p, float64(k)) * math.Pow(1-p, float64(n-k))
+	}
+	return total
+}
+
+func binomial(n, k int) float64 {
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+	return result
+}
+
+// Simulated MPC agreement: each participant succeeds with mpcConfig.ParticipantReliability
 func simulateMPC(validators int) bool {
-	return rand.Float64() < 0.95
+	n, t := mpcConfig.resolve(validators)
+	if n <= 0 || t > n {
+		return false
+	}
+	succeeded := 0
+	for i := 0; i < n; i++ {
+		if rand.Float64() < mpcConfig.ParticipantReliability {
+			succeeded++
+		}
+	}
+	return succeeded >= t
 }
 
 // Simulated ZK proof verification
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// Approves or rejects every block
+type fixedVote bool
+
+func (f fixedVote) Vote(id string, v *ValidatorProfile, blockHash string) (bool, float64) {
+	if f {
+		return true, 1
+	}
+	return false, 0
+}
+
+// Passes every ZK check and MPC round, counting the MPC rounds
+type countingProofProvider struct{ mpcRuns int }
+
+func (p *countingProofProvider) VerifyZK(string) bool { return true }
+
+func (p *countingProofProvider) RunMPC(int) bool {
+	p.mpcRuns++
+	return true
+}
+
+// Swaps in strategy and provider for one test
+func useConsensusStubs(strategy VoteStrategy, provider ExternalProofProvider) (restore func()) {
+	previousStrategy, previousProvider := voteStrategy, proofProvider
+	voteStrategy, proofProvider = strategy, provider
+	return func() { voteStrategy, proofProvider = previousStrategy, previousProvider }
+}
+
+func TestFastPathIsFlaggedOnTheBlock(t *testing.T) {
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"A": testValidator(0.95, "US"),
+		"B": testValidator(0.95, "EU"),
+	})()
+	provider := &countingProofProvider{}
+	defer useConsensusStubs(fixedVote(true), provider)()
+
+	block := Block{BlockHeader: BlockHeader{Hash: "fast"}}
+	if !dBFTConsensus(context.Background(), &block) {
+		t.Fatal("unanimous high-trust approval rejected")
+	}
+	if !block.FastPath || block.MPCVerified || provider.mpcRuns != 0 {
+		t.Fatalf("FastPath %t MPCVerified %t after %d MPC rounds, want fast path without MPC", block.FastPath, block.MPCVerified, provider.mpcRuns)
+	}
+	if block.Consensus == nil || !block.Consensus.FastPath {
+		t.Fatal("consensus record does not show the fast path")
+	}
+}
+
+func TestSlowPathRunsMPC(t *testing.T) {
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"A": testValidator(0.95, "US"),
+		"B": testValidator(0.7, "EU"),
+	})()
+	provider := &countingProofProvider{}
+	defer useConsensusStubs(fixedVote(true), provider)()
+
+	block := Block{BlockHeader: BlockHeader{Hash: "slow"}, FastPath: true}
+	if !dBFTConsensus(context.Background(), &block) {
+		t.Fatal("unanimous approval rejected")
+	}
+	if block.FastPath || !block.MPCVerified || provider.mpcRuns != 1 {
+		t.Fatalf("FastPath %t MPCVerified %t after %d MPC rounds, want one verified MPC round", block.FastPath, block.MPCVerified, provider.mpcRuns)
+	}
+}
+
+// Passes every ZK check but fails every MPC round
+type failingMPC struct{}
+
+func (failingMPC) VerifyZK(string) bool { return true }
+func (failingMPC) RunMPC(int) bool      { return false }
+
+func TestMPCFailurePolicy(t *testing.T) {
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"A": testValidator(0.95, "US"),
+		"B": testValidator(0.7, "EU"),
+	})()
+	defer useConsensusStubs(fixedVote(true), failingMPC{})()
+	previous := mpcFailurePolicy
+	defer func() { mpcFailurePolicy = previous }()
+
+	mpcFailurePolicy = MPCFailClosed
+	closed := Block{BlockHeader: BlockHeader{Hash: "closed"}}
+	if dBFTConsensus(context.Background(), &closed) {
+		t.Fatal("fail-closed accepted a block whose MPC round failed")
+	}
+
+	mpcFailurePolicy = MPCFailOpen
+	open := Block{BlockHeader: BlockHeader{Hash: "open"}}
+	if !dBFTConsensus(context.Background(), &open) {
+		t.Fatal("fail-open rejected the block")
+	}
+	if open.MPCVerified || open.Consensus == nil {
+		t.Fatalf("fail-open block MPCVerified %t, record %v; want flagged unverified with a record", open.MPCVerified, open.Consensus)
+	}
+}
+
+// Approves every block except for the listed validators
+type rejectFrom map[string]bool
+
+func (r rejectFrom) Vote(id string, v *ValidatorProfile, blockHash string) (bool, float64) {
+	if r[id] {
+		return false, 0
+	}
+	return true, 1
+}
+
+func TestConsensusRecordIsStoredWithTheBlock(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1})
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"A": testValidator(0.9, "US"),
+		"B": testValidator(0.9, "EU"),
+		"C": testValidator(0.9, "AS"),
+		"D": testValidator(0.9, "SA"),
+	})()
+	defer useConsensusStubs(rejectFrom{"D": true}, &countingProofProvider{})()
+
+	receipt, err := AddBlockCtx(context.Background(), "audited", "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, ok := ConsensusRecordFor(receipt.Shard, receipt.Height)
+	if !ok {
+		t.Fatal("no consensus record stored")
+	}
+	if math.Abs(record.Ratio-0.75) > 1e-9 || math.Abs(record.Ratio-record.ApprovedTrust/record.TotalTrust) > 1e-9 {
+		t.Fatalf("ratio %v (approved %v of %v), want 0.75", record.Ratio, record.ApprovedTrust, record.TotalTrust)
+	}
+	if record.Ratio < record.Threshold {
+		t.Fatalf("accepted with ratio %v below threshold %v", record.Ratio, record.Threshold)
+	}
+	var voters []string
+	for _, vote := range record.Votes {
+		voters = append(voters, fmt.Sprintf("%s:%t", vote.Validator, vote.Approved))
+	}
+	if fmt.Sprint(voters) != "[A:true B:true C:true D:false]" {
+		t.Fatalf("votes %v", voters)
+	}
+	if _, ok := ConsensusRecordFor(receipt.Shard, 0); ok {
+		t.Fatal("genesis has a consensus record")
+	}
+}
+
+func TestVoterCapKeepsTheMostTrusted(t *testing.T) {
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"A": testValidator(0.95, "US"),
+		"B": testValidator(0.6, "EU"),
+		"C": testValidator(0.9, "AS"),
+		"D": testValidator(0.5, "SA"),
+		"E": testValidator(0.85, "AF"),
+		"F": testValidator(0.7, "OC"),
+	})()
+	defer useConsensusStubs(fixedVote(true), &countingProofProvider{})()
+	previous := consensusConfig
+	defer func() { consensusConfig = previous }()
+	consensusConfig.MaxVotersPerRound = 3
+
+	block := Block{BlockHeader: BlockHeader{Hash: "capped"}}
+	if !dBFTConsensus(context.Background(), &block) {
+		t.Fatal("block rejected")
+	}
+	var voters []string
+	for _, vote := range block.Consensus.Votes {
+		voters = append(voters, vote.Validator)
+	}
+	if fmt.Sprint(voters) != "[A C E]" {
+		t.Fatalf("voters %v, want the three most trusted A, C and E", voters)
+	}
+}
+
+func TestMiningGivesUpPastMaxNonce(t *testing.T) {
+	defer SetMaxNonce(maxNonce)
+	SetMaxNonce(5000)
+
+	start := time.Now()
+	stats, err := mineBlockAt(Block{BlockHeader: BlockHeader{Timestamp: formatBlockTime(genesisTime)}}, 256)
+	if !errors.Is(err, errNonceExhausted) {
+		t.Fatalf("mining at 256 bits: %v, want errNonceExhausted", err)
+	}
+	if stats.Tries != 5001 {
+		t.Fatalf("%d tries, want nonces 0 through 5000", stats.Tries)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("exhausting 5000 nonces took %v", elapsed)
+	}
+}
+
+func TestVoteStrategyDecidesTheBlock(t *testing.T) {
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"A": testValidator(0.9, "US"),
+		"B": testValidator(0.8, "EU"),
+		"C": testValidator(0.7, "AS"),
+	})()
+	for _, strategy := range []fixedVote{true, false} {
+		restore := useConsensusStubs(strategy, &countingProofProvider{})
+		block := Block{BlockHeader: BlockHeader{Hash: fmt.Sprint("strategy ", strategy)}}
+		if got := dBFTConsensus(context.Background(), &block); got != bool(strategy) {
+			t.Errorf("always-%t strategy: accepted %t", strategy, got)
+		}
+		restore()
+	}
+}
+
+func TestWeightedScoreIsDeterministicPerValidatorAndBlock(t *testing.T) {
+	v := testValidator(0.8, "US")
+	vote, score := WeightedScoreStrategy{}.Vote("V1", v, "block")
+	for i := 0; i < 3; i++ {
+		if again, againScore := (WeightedScoreStrategy{}).Vote("V1", v, "block"); again != vote || againScore != score {
+			t.Fatal("same validator and block scored differently")
+		}
+	}
+	scores := make(map[float64]bool)
+	for _, id := range []string{"V1", "V2", "V3", "V4", "V5"} {
+		_, s := WeightedScoreStrategy{}.Vote(id, v, "block")
+		scores[s] = true
+	}
+	if len(scores) < 2 {
+		t.Fatal("identical profiles under different ids all drew the same randomness")
+	}
+}
+
+func TestTwoThirdsStakeQuorum(t *testing.T) {
+	staked := func(stake int, location string) *ValidatorProfile {
+		v := testValidator(0.9, location)
+		v.StakeLevel = stake
+		return v
+	}
+	previous := quorumPolicy
+	defer func() { quorumPolicy = previous }()
+	quorumPolicy = QuorumTwoThirdsStake
+
+	cases := []struct {
+		reject map[string]bool
+		want   bool
+	}{
+		{rejectFrom{"C": true, "D": true}, false}, // A, B and E hold 6 of 10 stake
+		{rejectFrom{"D": true, "E": true}, true},  // A, B and C hold 7 of 10
+	}
+	for _, c := range cases {
+		restoreValidators := UseTestValidators(map[string]*ValidatorProfile{
+			"A": staked(3, "US"),
+			"B": staked(2, "EU"),
+			"C": staked(2, "AS"),
+			"D": staked(2, "SA"),
+			"E": staked(1, "AF"),
+		})
+		restoreStubs := useConsensusStubs(rejectFrom(c.reject), &countingProofProvider{})
+		block := Block{BlockHeader: BlockHeader{Hash: fmt.Sprint("stake ", c.reject)}}
+		if got := dBFTConsensus(context.Background(), &block); got != c.want {
+			t.Errorf("rejections from %v: accepted %t, want %t", c.reject, got, c.want)
+		}
+		restoreStubs()
+		restoreValidators()
+	}
+}
+
+func BenchmarkDBFTConsensus(b *testing.B) {
+	for _, n := range []int{4, 16, 64} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			set := make(map[string]*ValidatorProfile, n)
+			for i := 0; i < n; i++ {
+				set[fmt.Sprintf("V%d", i)] = testValidator(0.9, fmt.Sprintf("R%d", i))
+			}
+			defer UseTestValidators(set)()
+			defer useConsensusStubs(WeightedScoreStrategy{}, &countingProofProvider{})()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				block := Block{BlockHeader: BlockHeader{Index: i, Hash: fmt.Sprint(i)}}
+				dBFTConsensus(context.Background(), &block)
+			}
+		})
+	}
+}
+
+func BenchmarkWeightedScoreVote(b *testing.B) {
+	v := testValidator(0.8, "US")
+	for i := 0; i < b.N; i++ {
+		WeightedScoreStrategy{}.Vote("V1", v, fmt.Sprint(i))
+	}
+}
+
+func TestMiningStatsAtDifficultyOne(t *testing.T) {
+	defer UseTestDifficulty()()
+
+	const blocks = 200
+	var tries int
+	var elapsed time.Duration
+	for i := 0; i < blocks; i++ {
+		block := Block{BlockHeader: BlockHeader{Index: i, Timestamp: formatBlockTime(genesisTime)}}
+		stats, err := mineBlock(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Tries != stats.Nonce+1 || stats.Bits != 4 {
+			t.Fatalf("block %d: stats %+v, want Tries = Nonce+1 and 4 bits", i, stats)
+		}
+		tries += stats.Tries
+		elapsed += stats.Duration
+	}
+	if avg := float64(tries) / blocks; avg < 8 || avg > 32 {
+		t.Fatalf("average %.1f tries at difficulty 1, want around %.0f", avg, expectedTries(4))
+	}
+	if elapsed <= 0 {
+		t.Fatal("mining durations not recorded")
+	}
+}
+
+func TestSetDifficultyOneMinesQuickly(t *testing.T) {
+	previous := difficultyBits
+	defer func() { difficultyBits = previous }()
+	SetDifficulty(1)
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		block := Block{BlockHeader: BlockHeader{Index: i, Timestamp: formatBlockTime(genesisTime)}}
+		stats, err := mineBlock(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.Nonce, block.Bits = stats.Nonce, stats.Bits
+		block.seal()
+		if block.Hash[0] != '0' {
+			t.Fatalf("block %d hash %s does not start with a zero", i, block.Hash)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("100 blocks at difficulty 1 took %v", elapsed)
+	}
+
+	SetDifficulty(0)
+	if difficultyBits != 4*defaultDifficulty {
+		t.Fatalf("SetDifficulty(0) left %d bits, want the default %d", difficultyBits, 4*defaultDifficulty)
+	}
+}
+
+func TestMinRegionsRejectsSingleRegionApproval(t *testing.T) {
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"A": testValidator(0.9, "US"),
+		"B": testValidator(0.9, "US"),
+		"C": testValidator(0.9, "EU"),
+	})()
+	previous := consensusConfig
+	defer func() { consensusConfig = previous }()
+	consensusConfig.MinRegions = 2
+
+	restore := useConsensusStubs(rejectFrom{"C": true}, &countingProofProvider{})
+	usOnly := Block{BlockHeader: BlockHeader{Hash: "us-only"}}
+	accepted := dBFTConsensus(context.Background(), &usOnly)
+	restore()
+	if accepted {
+		t.Fatal("block approved only by US validators accepted with MinRegions 2")
+	}
+
+	defer useConsensusStubs(rejectFrom{"B": true}, &countingProofProvider{})()
+	spread := Block{BlockHeader: BlockHeader{Hash: "us-eu"}}
+	if !dBFTConsensus(context.Background(), &spread) {
+		t.Fatal("block approved from US and EU rejected")
+	}
+}
+
+func TestHigherMPCThresholdLowersSuccess(t *testing.T) {
+	previous := 1.1
+	for threshold := 1; threshold <= 7; threshold++ {
+		p := MPCConfig{N: 7, Threshold: threshold, ParticipantReliability: 0.8}.SuccessProbability(0)
+		if p >= previous {
+			t.Fatalf("threshold %d succeeds with %.4f, not below %.4f at threshold %d", threshold, p, previous, threshold-1)
+		}
+		previous = p
+	}
+	if p := (MPCConfig{N: 7, Threshold: 7, ParticipantReliability: 0.8}).SuccessProbability(0); math.Abs(p-math.Pow(0.8, 7)) > 1e-12 {
+		t.Fatalf("7 of 7 succeeds with %.4f, want 0.8^7", p)
+	}
+	if p := (MPCConfig{N: 3, Threshold: 4, ParticipantReliability: 1}).SuccessProbability(0); p != 0 {
+		t.Fatalf("threshold above N succeeds with %.4f", p)
+	}
+
+	// The simulation follows the configured odds
+	defer func(c MPCConfig) { mpcConfig = c }(mpcConfig)
+	mpcConfig = MPCConfig{N: 5, Threshold: 4, ParticipantReliability: 0.7}
+	const rounds = 4000
+	passed := 0
+	for i := 0; i < rounds; i++ {
+		if simulateMPC(0) {
+			passed++
+		}
+	}
+	if want := mpcConfig.SuccessProbability(0); math.Abs(float64(passed)/rounds-want) > 0.05 {
+		t.Fatalf("simulated success rate %.3f, want about %.3f", float64(passed)/rounds, want)
+	}
+}
+
+func TestStaleValidatorCountsOnlyInAvailabilityMode(t *testing.T) {
+	stale := testValidator(0.8, "EU")
+	stale.LastPing = time.Now().Add(-2 * authTimeout)
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"A": testValidator(0.9, "US"),
+		"S": stale,
+	})()
+	defer useConsensusStubs(fixedVote(true), &countingProofProvider{})()
+	previous := currentState
+	defer func() { currentState = previous }()
+	defer SetTrustHalfLife(trustHalfLife)
+	SetTrustHalfLife(1000 * time.Hour) // keep idle decay out of the recorded trust
+
+	voteOf := func(hash string) (ValidatorVote, bool) {
+		block := Block{BlockHeader: BlockHeader{Hash: hash}}
+		if !dBFTConsensus(context.Background(), &block) {
+			t.Fatalf("%s rejected", hash)
+		}
+		for _, vote := range block.Consensus.Votes {
+			if vote.Validator == "S" {
+				return vote, true
+			}
+		}
+		return ValidatorVote{}, false
+	}
+
+	currentState = Consistency
+	if _, counted := voteOf("consistency"); counted {
+		t.Fatal("stale validator voted in Consistency mode")
+	}
+	currentState = Availability
+	vote, counted := voteOf("availability")
+	if !counted {
+		t.Fatal("stale validator excluded in Availability mode")
+	}
+	if want := 0.8 * consensusConfig.StaleTrustFactor; math.Abs(vote.Trust-want) > 1e-4 {
+		t.Fatalf("stale validator voted at trust %.3f, want the discounted %.3f", vote.Trust, want)
+	}
+	// Only a ping refreshes liveness; voting leaves the validator stale
+	if _, counted := voteOf("availability again"); !counted || time.Since(validators["S"].LastPing) < authTimeout {
+		t.Fatal("voting refreshed the stale validator's LastPing")
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Transfer from an account settled in the source shard to one credited in the target shard.
+// The source shard commits to the message in a block whose debit moves the amount into
+// crossShardEscrow; the target releases it to the recipient in a block of its own, only after
+// proving the carrying block against the source shard's Merkle root.
+type CrossShardMessage struct {
+	Source    int
+	Target    int
+	From      string
+	To        string
+	Amount    uint64
+	BlockHash string // source block carrying the message, set once it is committed
+}
+
+// Per-shard queues: messages a shard has sent, and messages waiting to be applied by it;
+// deliveredMessages holds the carrying block hash of every message already credited
+var (
+	outboundMessages  = make(map[int][]CrossShardMessage)
+	inboundMessages   = make(map[int][]CrossShardMessage)
+	deliveredMessages = make(map[string]bool)
+)
+
+// Account holding transfers between their debit on the source shard and credit on the target
+const crossShardEscrow = "xshard:escrow"
+
+// Block data committing to the message
+func (m CrossShardMessage) payload() string {
+	body, _ := json.Marshal(struct {
+		Source, Target int
+		From, To       string
+		Amount         uint64
+	}{m.Source, m.Target, m.From, m.To, m.Amount})
+	return "xshard:" + string(body)
+}
+
+// Transaction moving the amount from the sender into escrow, carried by the source block
+func (m CrossShardMessage) debit() Transaction {
+	return Transaction{From: m.From, To: crossShardEscrow, Amount: m.Amount, Nonce: ledger.Nonces[m.From]}
+}
+
+// Transactions releasing msgs from escrow to their recipients, in order
+func crossShardCredits(msgs []CrossShardMessage) []Transaction {
+	txs := make([]Transaction, len(msgs))
+	nonce := ledger.Nonces[crossShardEscrow]
+	for i, msg := range msgs {
+		txs[i] = Transaction{From: crossShardEscrow, To: msg.To, Amount: msg.Amount, Nonce: nonce + uint64(i)}
+	}
+	return txs
+}
+
+// Commits the message, with its debit, in a block on the source shard and queues it outbound
+func sendCrossShardMessage(msg CrossShardMessage, validator string) error {
+	defer lockForestWrite()()
+	if msg.Source < 0 || msg.Source >= len(merkleForest) || msg.Target < 0 || msg.Target >= len(merkleForest) {
+		return fmt.Errorf("cross-shard message %d -> %d: shard out of range", msg.Source, msg.Target)
+	}
+	if ledger.Balances[msg.From] < msg.Amount {
+		return fmt.Errorf("cross-shard message: insufficient funds in %s", msg.From)
+	}
+	receipt, err := addBlockToShardCtx(context.Background(), msg.Source, msg.payload(), validator, msg.debit())
+	if err != nil {
+		return err
+	}
+	msg.BlockHash = receipt.Hash
+	outboundMessages[msg.Source] = append(outboundMessages[msg.Source], msg)
+	return nil
+}
+
+// Moves every outbound message addressed to shardIndex into its inbound queue
+func relayCrossShardMessages(shardIndex int) {
+	for source, queue := range outboundMessages {
+		var kept []CrossShardMessage
+		for _, msg := range queue {
+			if msg.Target == shardIndex {
+				inboundMessages[shardIndex] = append(inboundMessages[shardIndex], msg)
+			} else {
+				kept = append(kept, msg)
+			}
+		}
+		outboundMessages[source] = kept
+	}
+}
+
+// Credits the shard's inbound messages whose source block proves into the source shard and
+// carries exactly the message, in one block on the shard proposed by validator; the rest stay
+// queued for a later pass. A message delivered before is dropped rather than credited again.
+// Returns how many were credited.
+func processCrossShardMessages(shardIndex int, validator string) int {
+	defer lockForestWrite()()
+	relayCrossShardMessages(shardIndex)
+
+	var pending, ready []CrossShardMessage
+	var hashes []string
+	seen := make(map[string]bool)
+	for _, msg := range inboundMessages[shardIndex] {
+		if deliveredMessages[msg.BlockHash] || seen[msg.BlockHash] {
+			fmt.Printf("Cross-shard message from block %s already delivered, dropped\n", shortHash(msg.BlockHash))
+			continue
+		}
+		if err := verifyCrossShardMessage(msg); err != nil {
+			fmt.Println("Cross-shard message held:", err)
+			pending = append(pending, msg)
+			continue
+		}
+		seen[msg.BlockHash] = true
+		ready = append(ready, msg)
+		hashes = append(hashes, msg.BlockHash)
+	}
+	if len(ready) > 0 {
+		data := "xshard-in:" + strings.Join(hashes, ",")
+		if _, err := produceBlock(context.Background(), shardIndex, data, validator, crossShardCredits(ready)...); err != nil {
+			fmt.Println("Cross-shard credits not committed:", err)
+			inboundMessages[shardIndex] = append(pending, ready...)
+			return 0
+		}
+		for _, hash := range hashes {
+			deliveredMessages[hash] = true
+		}
+	}
+	inboundMessages[shardIndex] = pending
+	return len(ready)
+}
+
+// Finds the carrying block in the source shard, checks its inclusion proof, payload and debit
+func verifyCrossShardMessage(msg CrossShardMessage) error {
+	for pos, block := range merkleForest[msg.Source].Blocks {
+		if block.Hash != msg.BlockHash {
+			continue
+		}
+		if err := validateBlockHash(block, merkleForest[msg.Source]); err != nil {
+			return err
+		}
+		if !shardMerkleProof(msg.Source, pos).Verify() {
+			return fmt.Errorf("block %s: Merkle proof against shard %d failed", shortHash(block.Hash), msg.Source)
+		}
+		data, err := blockPlaintext(block, shardKeys[msg.Source])
+		if err != nil {
+			return err
+		}
+		if data != msg.payload() || !carriesDebit(block, msg) {
+			return fmt.Errorf("block %s does not carry the message", shortHash(block.Hash))
+		}
+		return nil
+	}
+	return fmt.Errorf("block %s not found in shard %d", shortHash(msg.BlockHash), msg.Source)
+}
+
+// Reports whether block moves the message's amount from its sender into escrow
+func carriesDebit(block Block, msg CrossShardMessage) bool {
+	for _, tx := range block.Transactions {
+		if tx.From == msg.From && tx.To == crossShardEscrow && tx.Amount == msg.Amount {
+			return true
+		}
+	}
+	return false
+}
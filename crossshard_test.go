@@ -0,0 +1,96 @@
+package main
+
+import "testing"
+
+func TestCrossShardMessageIsProvedAndApplied(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 2, Balances: map[string]uint64{"alice": 100}})
+	defer useConsensusStubs(fixedVote(true), &countingProofProvider{})()
+
+	msg := CrossShardMessage{Source: 0, Target: 1, From: "alice", To: "bob", Amount: 30}
+	if err := sendCrossShardMessage(msg, "Validator1"); err != nil {
+		t.Fatal(err)
+	}
+	if ledger.Balances["alice"] != 70 || ledger.Balances["bob"] != 0 {
+		t.Fatalf("after sending: alice %d bob %d, want 70 and 0", ledger.Balances["alice"], ledger.Balances["bob"])
+	}
+	if applied := processCrossShardMessages(0, "Validator1"); applied != 0 {
+		t.Fatalf("source shard applied %d of its own messages", applied)
+	}
+
+	if applied := processCrossShardMessages(1, "Validator2"); applied != 1 || ledger.Balances["bob"] != 30 {
+		t.Fatalf("applied %d, bob %d; want 1 message crediting 30", applied, ledger.Balances["bob"])
+	}
+	if applied := processCrossShardMessages(1, "Validator2"); applied != 0 || ledger.Balances["bob"] != 30 {
+		t.Fatal("message applied twice")
+	}
+}
+
+func TestForgedCrossShardMessageIsHeld(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 2, Balances: map[string]uint64{"alice": 100}})
+	defer useConsensusStubs(fixedVote(true), &countingProofProvider{})()
+
+	if err := sendCrossShardMessage(CrossShardMessage{Source: 0, Target: 1, From: "alice", To: "bob", Amount: 1}, "Validator1"); err != nil {
+		t.Fatal(err)
+	}
+	forged := outboundMessages[0][0]
+	forged.Amount = 1000
+	outboundMessages[0][0] = forged
+
+	if applied := processCrossShardMessages(1, "Validator2"); applied != 0 || ledger.Balances["bob"] != 0 {
+		t.Fatalf("forged amount applied: bob %d", ledger.Balances["bob"])
+	}
+	if len(inboundMessages[1]) != 1 {
+		t.Fatal("forged message dropped instead of held")
+	}
+}
+
+func TestCrossShardTransferIsCarriedByBlocks(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 2, Balances: map[string]uint64{"alice": 100}})
+	defer useConsensusStubs(fixedVote(true), &countingProofProvider{})()
+
+	msg := CrossShardMessage{Source: 0, Target: 1, From: "alice", To: "bob", Amount: 30}
+	if err := sendCrossShardMessage(msg, "Validator1"); err != nil {
+		t.Fatal(err)
+	}
+	source := merkleForest[0].Blocks[len(merkleForest[0].Blocks)-1]
+	debit := Transaction{From: "alice", To: crossShardEscrow, Amount: 30}
+	if len(source.Transactions) != 1 || source.Transactions[0] != debit || !ledger.Applied[source.Hash] {
+		t.Fatalf("carrying block holds %+v, want the applied debit %+v", source.Transactions, debit)
+	}
+	if ledger.Balances[crossShardEscrow] != 30 || ledger.Nonces["alice"] != 1 {
+		t.Fatalf("escrow %d, alice's nonce %d after the debit; want 30 and 1", ledger.Balances[crossShardEscrow], ledger.Nonces["alice"])
+	}
+
+	processCrossShardMessages(1, "Validator2")
+	target := merkleForest[1].Blocks[len(merkleForest[1].Blocks)-1]
+	credit := Transaction{From: crossShardEscrow, To: "bob", Amount: 30}
+	if len(target.Transactions) != 1 || target.Transactions[0] != credit || !ledger.Applied[target.Hash] {
+		t.Fatalf("receiving block holds %+v, want the applied credit %+v", target.Transactions, credit)
+	}
+	if ledger.Balances[crossShardEscrow] != 0 || ledger.Balances["bob"] != 30 {
+		t.Fatalf("escrow %d bob %d after the credit, want 0 and 30", ledger.Balances[crossShardEscrow], ledger.Balances["bob"])
+	}
+}
+
+func TestDuplicateCrossShardDeliveryIsCreditedOnce(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 2, Balances: map[string]uint64{"alice": 100}})
+	defer useConsensusStubs(fixedVote(true), &countingProofProvider{})()
+
+	if err := sendCrossShardMessage(CrossShardMessage{Source: 0, Target: 1, From: "alice", To: "bob", Amount: 30}, "Validator1"); err != nil {
+		t.Fatal(err)
+	}
+	msg := outboundMessages[0][0]
+	outboundMessages[0] = append(outboundMessages[0], msg) // delivered twice in one pass
+	if applied := processCrossShardMessages(1, "Validator2"); applied != 1 || ledger.Balances["bob"] != 30 {
+		t.Fatalf("applied %d, bob %d; want the message credited once", applied, ledger.Balances["bob"])
+	}
+
+	height := len(merkleForest[1].Blocks)
+	inboundMessages[1] = append(inboundMessages[1], msg) // redelivered after it was credited
+	if applied := processCrossShardMessages(1, "Validator2"); applied != 0 || ledger.Balances["bob"] != 30 {
+		t.Fatalf("redelivery applied %d, bob %d", applied, ledger.Balances["bob"])
+	}
+	if len(merkleForest[1].Blocks) != height || len(inboundMessages[1]) != 0 {
+		t.Fatal("redelivered message produced a block or stayed queued")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+)
+
+// Selective disclosure: a transaction is committed to as a Merkle root over salted field leaves.
+// Opening one leaf (value + salt + path) proves that field without revealing the others.
+// "amountRange" is the power-of-two bucket holding the amount, so a range can be shown
+// without the exact value.
+var txFields = []string{"from", "to", "amount", "amountRange", "nonce", "gas", "fee"}
+
+// Per-node secret from which leaf salts are derived; unopened leaves stay hidden behind it
+var commitmentKey = func() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}()
+
+// Hiding commitment to a transaction's fields
+type Commitment struct {
+	Root string
+}
+
+// Opening of one committed field
+type FieldProof struct {
+	Field    string
+	Value    string
+	Salt     string
+	Index    int
+	Siblings []string
+}
+
+func commitTx(tx Transaction) Commitment {
+	return Commitment{Root: merkleRootOfHashes(txFieldLeaves(tx))}
+}
+
+// Opens one field of the transaction's commitment
+func proveField(tx Transaction, field string) (FieldProof, error) {
+	index := txFieldIndex(field)
+	if index < 0 {
+		return FieldProof{}, fmt.Errorf("unknown transaction field %q", field)
+	}
+	return FieldProof{
+		Field:    field,
+		Value:    txFieldValue(tx, field),
+		Salt:     fieldSalt(tx, field),
+		Index:    index,
+		Siblings: merkleProofOfHashes(txFieldLeaves(tx), index),
+	}, nil
+}
+
+// Checks the opened field against the commitment; the index must be the field's fixed slot
+func verifyFieldProof(c Commitment, p FieldProof) bool {
+	if txFieldIndex(p.Field) != p.Index {
+		return false
+	}
+	return verifyMerkleProofOfHashes(fieldLeaf(p.Field, p.Value, p.Salt), p.Index, p.Siblings, c.Root)
+}
+
+// Power-of-two bucket [2^k, 2^(k+1)) holding the amount, "[0,1)" for zero
+func amountBucket(amount uint64) string {
+	if amount == 0 {
+		return "[0,1)"
+	}
+	low := uint64(1)
+	for amount/low >= 2 {
+		low *= 2
+	}
+	if low == 1<<63 {
+		return fmt.Sprintf("[%d,inf)", low)
+	}
+	return fmt.Sprintf("[%d,%d)", low, low*2)
+}
+
+func txFieldIndex(field string) int {
+	for i, f := range txFields {
+		if f == field {
+			return i
+		}
+	}
+	return -1
+}
+
+func txFieldValue(tx Transaction, field string) string {
+	switch field {
+	case "from":
+		return tx.From
+	case "to":
+		return tx.To
+	case "amount":
+		return strconv.FormatUint(tx.Amount, 10)
+	case "amountRange":
+		return amountBucket(tx.Amount)
+	case "nonce":
+		return strconv.FormatUint(tx.Nonce, 10)
+	case "gas":
+		return strconv.FormatUint(tx.Gas, 10)
+	case "fee":
+		return strconv.FormatUint(tx.Fee, 10)
+	}
+	return ""
+}
+
+func fieldSalt(tx Transaction, field string) string {
+	mac := hmac.New(sha256.New, commitmentKey)
+	mac.Write([]byte(tx.Hash() + ":" + field))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func fieldLeaf(field, value, salt string) string {
+	sum := sha256.Sum256([]byte(field + ":" + value + ":" + salt))
+	return hex.EncodeToString(sum[:])
+}
+
+func txFieldLeaves(tx Transaction) []string {
+	leaves := make([]string, len(txFields))
+	for i, field := range txFields {
+		leaves[i] = fieldLeaf(field, txFieldValue(tx, field), fieldSalt(tx, field))
+	}
+	return leaves
+}
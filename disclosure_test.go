@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestFieldProofsAgainstTheCommitment(t *testing.T) {
+	tx := Transaction{From: "alice", To: "bob", Amount: 300, Nonce: 2, Gas: 21, Fee: 5}
+	c := commitTx(tx)
+
+	for _, field := range txFields {
+		p, err := proveField(tx, field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !verifyFieldProof(c, p) {
+			t.Fatalf("proof of %s failed", field)
+		}
+	}
+
+	rangeProof, _ := proveField(tx, "amountRange")
+	if rangeProof.Value != "[256,512)" {
+		t.Fatalf("amount 300 disclosed as range %s, want [256,512)", rangeProof.Value)
+	}
+
+	mismatched, _ := proveField(tx, "amount")
+	mismatched.Value = "3000"
+	if verifyFieldProof(c, mismatched) {
+		t.Fatal("a different amount verified")
+	}
+	relabelled, _ := proveField(tx, "to")
+	relabelled.Field = "from" // bob's leaf passed off as the sender
+	if verifyFieldProof(c, relabelled) {
+		t.Fatal("a field opened under another field's name verified")
+	}
+	other, _ := proveField(Transaction{From: "carol", To: "bob", Amount: 300}, "from")
+	if verifyFieldProof(c, other) {
+		t.Fatal("another transaction's opening verified")
+	}
+	if _, err := proveField(tx, "signature"); err == nil {
+		t.Fatal("unknown field opened")
+	}
+}
+
+func TestAmountBuckets(t *testing.T) {
+	cases := map[uint64]string{0: "[0,1)", 1: "[1,2)", 3: "[2,4)", 4: "[4,8)", 1 << 63: "[9223372036854775808,inf)"}
+	for amount, want := range cases {
+		if got := amountBucket(amount); got != want {
+			t.Errorf("amountBucket(%d) = %s, want %s", amount, got, want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Graphviz rendering of the forest: one cluster per shard with its blocks and PrevHash edges,
+// and an edge from each shard root to the forest root
+func ForestToDOT() string {
+	var b strings.Builder
+	b.WriteString("digraph forest {\n")
+	b.WriteString("  rankdir=LR;\n")
+	fmt.Fprintf(&b, "  forest_root [shape=doubleoctagon, label=\"forest %s\"];\n", shortHash(ForestRoot()))
+
+	for i, shard := range merkleForest {
+		fmt.Fprintf(&b, "  subgraph cluster_shard%d {\n", i)
+		fmt.Fprintf(&b, "    label=\"shard %d\";\n", i)
+		fmt.Fprintf(&b, "    s%d_root [shape=octagon, label=\"root %s\"];\n", i, shortHash(shard.MerkleRoot))
+
+		position := make(map[string]int, len(shard.Blocks))
+		for pos, block := range shard.Blocks {
+			fmt.Fprintf(&b, "    s%d_b%d [shape=box, label=\"#%d %s\"];\n", i, pos, block.Index, shortHash(block.Hash))
+			position[block.Hash] = pos
+		}
+		for pos, block := range shard.Blocks {
+			if parent, ok := position[block.PrevHash]; ok && block.PrevHash != "" {
+				fmt.Fprintf(&b, "    s%d_b%d -> s%d_b%d;\n", i, pos, i, parent)
+			}
+		}
+		b.WriteString("  }\n")
+		fmt.Fprintf(&b, "  s%d_root -> forest_root;\n", i)
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestForestToDOTCountsNodesAndEdges(t *testing.T) {
+	defer UseTestDifficulty()()
+	defer InstallTestForest(NewTestForest(2, 3))()
+	dot := ForestToDOT()
+
+	if !strings.HasPrefix(dot, "digraph forest {") || !strings.HasSuffix(dot, "}\n") {
+		t.Fatalf("not a digraph:\n%s", dot)
+	}
+	blocks := strings.Count(dot, "[shape=box")
+	edges := strings.Count(dot, " -> ")
+	// Two PrevHash links per three-block shard, plus one root edge per shard
+	if blocks != 6 || edges != 2*2+2 {
+		t.Fatalf("%d block nodes and %d edges, want 6 and 6:\n%s", blocks, edges, dot)
+	}
+	for _, shard := range merkleForest {
+		for _, block := range shard.Blocks {
+			if !strings.Contains(dot, shortHash(block.Hash)) {
+				t.Fatalf("block %.12s missing from the graph", block.Hash)
+			}
+		}
+	}
+	if !strings.Contains(dot, "s1_b2 -> s1_b1;") || !strings.Contains(dot, "s0_root -> forest_root;") {
+		t.Fatalf("expected edges missing:\n%s", dot)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// Per-shard AES keys; blocks added to a shard with a key get their Data encrypted
+var shardKeys = make(map[int][]byte)
+
+// Enables payload encryption for a shard (AES-128/192/256 key)
+func SetShardKey(shardIndex int, key []byte) error {
+	if _, err := aes.NewCipher(key); err != nil {
+		return err
+	}
+	shardKeys[shardIndex] = append([]byte(nil), key...)
+	return nil
+}
+
+// AES-GCM seal; the result is base64(nonce || ciphertext), which is what the block hash commits to
+func encryptPayload(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Opens an encryptPayload result; fails on a wrong key or tampered payload
+func decryptPayload(key []byte, payload string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("payload too short")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt payload: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Plaintext of a block's Data, decrypting with key when the block is encrypted
+func blockPlaintext(block Block, key []byte) (string, error) {
+	if !block.Encrypted {
+		return block.Data, nil
+	}
+	return decryptPayload(key, block.Data)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestEncryptedBlockValidatesAndDecrypts(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1})
+	defer useConsensusStubs(fixedVote(true), &countingProofProvider{})()
+	key := bytes.Repeat([]byte{7}, 32)
+	if err := SetShardKey(0, key); err != nil {
+		t.Fatal(err)
+	}
+
+	receipt, err := AddBlockCtx(context.Background(), "secret payload", "Validator1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := merkleForest[0].Blocks[receipt.Height]
+	if !block.Encrypted || block.Data == "secret payload" {
+		t.Fatalf("block stored in the clear: encrypted %t, data %q", block.Encrypted, block.Data)
+	}
+	MustValidate(t, merkleForest)
+
+	plaintext, err := blockPlaintext(block, key)
+	if err != nil || plaintext != "secret payload" {
+		t.Fatalf("decrypted %q, %v", plaintext, err)
+	}
+	if _, err := blockPlaintext(block, bytes.Repeat([]byte{8}, 32)); err == nil {
+		t.Fatal("wrong key decrypted the payload")
+	}
+}
+
+func TestSetShardKeyRejectsBadKeys(t *testing.T) {
+	if err := SetShardKey(0, []byte("short")); err == nil {
+		t.Fatal("5-byte AES key accepted")
+	}
+}
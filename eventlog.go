@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of operation recorded in the event log
+type EventKind string
+
+const (
+	EventBlockAdded EventKind = "block-added" // committed or genesis block
+	EventSynced     EventKind = "synced"      // block copied to another shard by state sync
+	EventRebalanced EventKind = "rebalanced"  // tail block moved From -> Shard
+	EventConsensus  EventKind = "consensus"   // dBFT outcome for a proposed block
+	EventCAPMode    EventKind = "cap-mode"    // CAP orchestrator switched mode
+)
+
+// One recorded operation. From is the source shard for syncs and rebalances, -1 otherwise;
+// Shard is -1 for events that are not tied to a shard.
+type LoggedEvent struct {
+	Time   time.Time
+	Kind   EventKind
+	Shard  int
+	From   int
+	Hash   string
+	Detail string
+}
+
+// Append-only, in-memory record of significant operations, for debugging
+type EventLog struct {
+	mu     sync.Mutex
+	events []LoggedEvent
+}
+
+var eventLog = &EventLog{}
+
+// Records an event, stamping it with the current time if it has none
+func (l *EventLog) Append(e LoggedEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	l.mu.Lock()
+	l.events = append(l.events, e)
+	l.mu.Unlock()
+}
+
+// Calls fn for every event in the order they were appended
+func (l *EventLog) Replay(fn func(LoggedEvent)) {
+	l.mu.Lock()
+	events := append([]LoggedEvent(nil), l.events...)
+	l.mu.Unlock()
+	for _, e := range events {
+		fn(e)
+	}
+}
+
+// Shard heights implied by the log's block, sync and rebalance events
+func (l *EventLog) Heights() []int {
+	var heights []int
+	grow := func(i int) {
+		for len(heights) <= i {
+			heights = append(heights, 0)
+		}
+	}
+	l.Replay(func(e LoggedEvent) {
+		switch e.Kind {
+		case EventBlockAdded, EventSynced:
+			grow(e.Shard)
+			heights[e.Shard]++
+		case EventRebalanced:
+			grow(max(e.Shard, e.From))
+			heights[e.From]--
+			heights[e.Shard]++
+		}
+	})
+	return heights
+}
+
+// Renders the log as a plain-text sequence diagram, one arrow per event
+func (l *EventLog) SequenceDiagram() string {
+	var b strings.Builder
+	l.Replay(func(e LoggedEvent) {
+		ts := e.Time.Format("15:04:05.000")
+		switch e.Kind {
+		case EventSynced, EventRebalanced:
+			fmt.Fprintf(&b, "%s shard%d -> shard%d: %s %s\n", ts, e.From, e.Shard, e.Kind, shortHash(e.Hash))
+		case EventConsensus:
+			fmt.Fprintf(&b, "%s validators: %s %s %s\n", ts, e.Kind, shortHash(e.Hash), e.Detail)
+		case EventCAPMode:
+			fmt.Fprintf(&b, "%s orchestrator: %s %s\n", ts, e.Kind, e.Detail)
+		default:
+			fmt.Fprintf(&b, "%s shard%d: %s %s\n", ts, e.Shard, e.Kind, strings.TrimSpace(shortHash(e.Hash)+" "+e.Detail))
+		}
+	})
+	return b.String()
+}
+
+func logEvent(kind EventKind, shard, from int, hash, detail string) {
+	eventLog.Append(LoggedEvent{Kind: kind, Shard: shard, From: from, Hash: hash, Detail: detail})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEventLogReplayReproducesHeights(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 3})
+	SetMaxShardCapacity(3)
+	for i := 0; i < 5; i++ {
+		if err := addBlockToShards(fmt.Sprint("scripted ", i), "Validator1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rebalanceAll()
+
+	heights := eventLog.Heights()
+	if len(heights) != len(merkleForest) {
+		t.Fatalf("log implies %d shards, forest has %d", len(heights), len(merkleForest))
+	}
+	for i, shard := range merkleForest {
+		if heights[i] != len(shard.Blocks) {
+			t.Fatalf("log implies shard %d holds %d blocks, it holds %d", i, heights[i], len(shard.Blocks))
+		}
+	}
+
+	kinds := make(map[EventKind]int)
+	var last LoggedEvent
+	eventLog.Replay(func(e LoggedEvent) {
+		if e.Time.Before(last.Time) {
+			t.Errorf("%s event replayed out of time order", e.Kind)
+		}
+		kinds[e.Kind]++
+		last = e
+	})
+	// A rejected view adds a consensus event of its own before the next proposer tries
+	if kinds[EventConsensus] < 5 || kinds[EventSynced] == 0 || kinds[EventRebalanced] == 0 {
+		t.Fatalf("event counts %v", kinds)
+	}
+	diagram := eventLog.SequenceDiagram()
+	if lines := strings.Count(diagram, "\n"); lines != len(eventLog.events) {
+		t.Fatalf("diagram has %d lines for %d events", lines, len(eventLog.events))
+	}
+}
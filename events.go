@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Published after a block is committed to a shard
+type BlockEvent struct {
+	Shard    int
+	Position int
+	Block    Block
+}
+
+const eventBuffer = 64 // events a slow subscriber may fall behind before it misses some
+
+// Fan-out of block events; publishing never waits on a subscriber
+var (
+	subscribersMu sync.Mutex
+	subscribers   = make(map[chan BlockEvent]bool)
+)
+
+// Registers a subscriber to the active chain; call the returned func to stop receiving and close the channel
+func SubscribeBlocks() (<-chan BlockEvent, func()) {
+	ch := make(chan BlockEvent, eventBuffer)
+	subscribersMu.Lock()
+	set := subscribers // the chain's set, even if another chain is active at unsubscribe
+	set[ch] = true
+	subscribersMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			subscribersMu.Lock()
+			delete(set, ch)
+			subscribersMu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Delivers to every subscriber with room in its buffer; full subscribers miss the event
+func publishBlock(event BlockEvent) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	for ch := range subscribers {
+		select {
+		case ch <- event:
+		default:
+			fmt.Printf("Event for block %s dropped: subscriber full\n", shortHash(event.Block.Hash))
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+const defaultFinalityDepth = 6 // confirmations before a block is final
+
+var finalityDepth = defaultFinalityDepth
+
+// Sets how many descendants a block needs before it is final; k < 1 restores the default
+func SetFinalityDepth(k int) {
+	if k < 1 {
+		k = defaultFinalityDepth
+	}
+	finalityDepth = k
+}
+
+// A block is final once at least finalityDepth blocks follow it in its shard
+func IsFinalized(shardIndex, blockIndex int) bool {
+	if shardIndex < 0 || shardIndex >= len(merkleForest) {
+		return false
+	}
+	blocks := merkleForest[shardIndex].Blocks
+	if blockIndex < 0 || blockIndex >= len(blocks) {
+		return false
+	}
+	return len(blocks)-1-blockIndex >= finalityDepth
+}
+
+// Position of the highest final block in a shard, or -1 if none is final yet
+func finalizedHeight(shardIndex int) int {
+	if shardIndex < 0 || shardIndex >= len(merkleForest) {
+		return -1
+	}
+	return max(len(merkleForest[shardIndex].Blocks)-1-finalityDepth, -1)
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestFinalityDepthSetsRequiredDescendants(t *testing.T) {
+	defer UseTestDifficulty()()
+	defer SetFinalityDepth(finalityDepth)
+	defer InstallTestForest(NewTestForest(1, 1))()
+
+	for _, depth := range []int{3, 6} {
+		SetFinalityDepth(depth)
+		merkleForest[0] = NewTestShard(0, 1)
+		for descendants := 0; descendants <= depth; descendants++ {
+			if got, want := IsFinalized(0, 0), descendants >= depth; got != want {
+				t.Fatalf("depth %d, %d descendants: finalized %t, want %t", depth, descendants, got, want)
+			}
+			tip := merkleForest[0].Blocks[len(merkleForest[0].Blocks)-1]
+			merkleForest[0].Blocks = append(merkleForest[0].Blocks, MineTestBlockAfter(tip, "descendant"))
+		}
+		if finalizedHeight(0) != 1 {
+			t.Fatalf("depth %d: finalized height %d with %d blocks, want 1", depth, finalizedHeight(0), len(merkleForest[0].Blocks))
+		}
+	}
+
+	SetFinalityDepth(0)
+	if finalityDepth != defaultFinalityDepth {
+		t.Fatalf("depth %d after SetFinalityDepth(0), want the default %d", finalityDepth, defaultFinalityDepth)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Writers hold this shared while they take forestMu; FreezeForRead holds it exclusively, so
+// writes queue behind a freeze while readers, which only take forestMu.RLock, carry on
+var writeGate sync.RWMutex
+
+// Takes forestMu for writing once no freeze is in force; call the returned func when done. The
+// entry points that change the forest, or the mode and partition state around it, take this;
+// the helpers behind them run with it held and must not take it again.
+func lockForestWrite() (unlock func()) {
+	writeGate.RLock()
+	forestMu.Lock()
+	return func() {
+		forestMu.Unlock()
+		writeGate.RUnlock()
+	}
+}
+
+// Blocks new forest writes until release is called, waiting first for any write in progress.
+// Reads keep going, so SaveForest can take a consistent backup while the node serves queries.
+func FreezeForRead() (release func()) {
+	writeGate.Lock()
+	var once sync.Once
+	return func() { once.Do(writeGate.Unlock) }
+}
+
+// On-disk backup written by SaveForest
+type forestFile struct {
+	ChainID string
+	Shards  []Shard
+}
+
+// Writes the forest to path as JSON, through a temporary file so a crash never leaves a partial
+// backup. Hold FreezeForRead around it to keep the forest from moving on while the backup, and
+// anything saved alongside it, is taken.
+func SaveForest(path string) error {
+	forestMu.RLock()
+	data, err := json.Marshal(forestFile{ChainID: chainID, Shards: merkleForest})
+	forestMu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("save forest: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("save forest: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("save forest: %w", err)
+	}
+	return nil
+}
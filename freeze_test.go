@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Every forest write must queue behind a freeze; each runs against a fresh two-shard forest
+func TestWritesWaitForFreeze(t *testing.T) {
+	defer UseTestDifficulty()()
+
+	writes := map[string]func(){
+		"ImportBlock": func() {
+			ImportBlock(0, MineTestBlockAfter(merkleForest[0].Blocks[0], "frozen"))
+		},
+		"SetShardState":    func() { SetShardState(0, ShardReadOnly) },
+		"ExecuteRebalance": func() { ExecuteRebalance(nil) },
+		"Reconcile": func() {
+			local, remote := cloneForest(merkleForest), cloneForest(merkleForest)
+			Reconcile(&local, &remote)
+		},
+		"Heal":             func() { Heal() },
+		"addBlockToShards": func() { addBlockToShards("frozen", "Validator1") },
+	}
+	for name, write := range writes {
+		t.Run(name, func(t *testing.T) {
+			defer InstallTestForest(NewTestForest(2, 1))()
+			before, err := json.Marshal(merkleForest)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			release := FreezeForRead()
+			defer release()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				write()
+			}()
+			select {
+			case <-done:
+				t.Fatal("write went through while frozen")
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			path := filepath.Join(t.TempDir(), "forest.json")
+			if err := SaveForest(path); err != nil {
+				t.Fatal(err)
+			}
+			release()
+			<-done
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var saved forestFile
+			if err := json.Unmarshal(data, &saved); err != nil {
+				t.Fatal(err)
+			}
+			after, _ := json.Marshal(saved.Shards)
+			if string(after) != string(before) {
+				t.Fatal("backup does not match the forest at the time of the freeze")
+			}
+		})
+	}
+}
+
+func TestReleaseIsIdempotent(t *testing.T) {
+	release := FreezeForRead()
+	release()
+	release()
+	unlock := lockForestWrite()
+	unlock()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Chain starting point: chain id, shard count, genesis timestamp, initial validator set and account balances
+type GenesisConfig struct {
+	ChainID     string            `json:"chainId,omitempty"` // defaultChainID when empty
+	Hash        string            `json:"hash,omitempty"`    // registered hasher name; sha256 when empty
+	ShardCount  int               `json:"shardCount"`
+	GenesisTime string            `json:"genesisTime,omitempty"` // RFC 3339; the Unix epoch when empty
+	Validators  []validatorRecord `json:"validators,omitempty"`
+	Balances    map[string]uint64 `json:"balances,omitempty"`
+}
+
+// Timestamp of every genesis block; fixed rather than wall-clock so genesis hashes are reproducible
+var genesisTime = time.Unix(0, 0).UTC()
+
+// Account balances the chain's blocks are applied to
+var ledger = newState()
+
+// Reads and checks a genesis description file
+func LoadGenesisConfig(path string) (GenesisConfig, error) {
+	var cfg GenesisConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse genesis %s: %w", path, err)
+	}
+	if cfg.ShardCount < 1 {
+		return cfg, fmt.Errorf("genesis %s: shardCount must be at least 1", path)
+	}
+	if cfg.GenesisTime != "" {
+		if _, err := parseBlockTime(cfg.GenesisTime); err != nil {
+			return cfg, fmt.Errorf("genesis %s: genesisTime: %w", path, err)
+		}
+	}
+	if _, err := HasherByName(cfg.Hash); err != nil {
+		return cfg, fmt.Errorf("genesis %s: %w", path, err)
+	}
+	seen := make(map[string]bool)
+	for _, v := range cfg.Validators {
+		if v.ID == "" || seen[v.ID] {
+			return cfg, fmt.Errorf("genesis %s: missing or duplicate validator id %q", path, v.ID)
+		}
+		seen[v.ID] = true
+	}
+	return cfg, nil
+}
+
+// Sets chain id, header hash, shard count, genesis time, validator set (when given) and initial
+// balances from the config. An unknown hash name falls back to the default.
+func applyGenesisConfig(cfg GenesisConfig) {
+	shardCount = cfg.ShardCount
+	chainID = defaultChainID
+	if cfg.ChainID != "" {
+		chainID = cfg.ChainID
+	}
+	blockHasher = hashers[defaultHasherName]
+	if h, err := HasherByName(cfg.Hash); err == nil {
+		blockHasher = h
+	}
+	genesisTime = time.Unix(0, 0).UTC()
+	if t, err := parseBlockTime(cfg.GenesisTime); err == nil {
+		genesisTime = t
+	}
+
+	if len(cfg.Validators) > 0 {
+		set := make(map[string]*ValidatorProfile, len(cfg.Validators))
+		for _, r := range cfg.Validators {
+			v := r.profile()
+			v.LastPing = time.Now()
+			set[r.ID] = v
+		}
+		withLocalKeys(set)
+		consensusMu.Lock()
+		validators = set
+		consensusMu.Unlock()
+	}
+
+	ledger = newState()
+	for account, balance := range cfg.Balances {
+		ledger.Balances[account] = balance
+	}
+}
+
+// Builds a fresh forest from the config: one genesis block per shard
+func initForest(cfg GenesisConfig) {
+	applyGenesisConfig(cfg)
+	initAMQFilters()
+
+	merkleForest = nil
+	for i := 0; i < shardCount; i++ {
+		genesis := createGenesisBlock(i)
+		merkleForest = append(merkleForest, newShard(i, genesis))
+		updateAMQ(i, genesis.Hash)
+		logEvent(EventBlockAdded, i, -1, genesis.Hash, "genesis")
+	}
+}
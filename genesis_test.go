@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const sampleGenesis = `{
+	"chainId": "testnet",
+	"shardCount": 3,
+	"validators": [
+		{"id": "G1", "trust": 0.9, "location": "US", "publicKey": "pk-g1", "stakeLevel": 3},
+		{"id": "G2", "trust": 0.8, "location": "EU", "publicKey": "pk-g2", "stakeLevel": 2}
+	],
+	"balances": {"alice": 100, "bob": 5}
+}`
+
+func writeGenesis(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "genesis.json")
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGenesisConfigBuildsTheForest(t *testing.T) {
+	cfg, err := LoadGenesisConfig(writeGenesis(t, sampleGenesis))
+	if err != nil {
+		t.Fatal(err)
+	}
+	useTestChain(t, cfg)
+
+	if len(merkleForest) != 3 || shardCount != 3 {
+		t.Fatalf("%d shards (shardCount %d), want 3", len(merkleForest), shardCount)
+	}
+	for i, shard := range merkleForest {
+		if len(shard.Blocks) != 1 || shard.Blocks[0].Index != 0 {
+			t.Fatalf("shard %d does not start with a lone genesis block", i)
+		}
+	}
+	if ledger.Balances["alice"] != 100 || ledger.Balances["bob"] != 5 {
+		t.Fatalf("balances %v, want alice 100 and bob 5", ledger.Balances)
+	}
+	if len(validators) != 2 || validators["G1"] == nil || validators["G2"].StakeLevel != 2 {
+		t.Fatalf("validators %v, want G1 and G2 from the file", validators)
+	}
+	if chainID != "testnet" {
+		t.Fatalf("chain id %q, want testnet", chainID)
+	}
+}
+
+func TestGenesisConfigRejectsBadFiles(t *testing.T) {
+	bad := map[string]string{
+		"no shards":    `{"shardCount": 0}`,
+		"bad time":     `{"shardCount": 1, "genesisTime": "yesterday"}`,
+		"unknown hash": `{"shardCount": 1, "hash": "md4"}`,
+		"duplicate id": `{"shardCount": 1, "validators": [{"id": "A"}, {"id": "A"}]}`,
+		"not json":     `shardCount: 1`,
+	}
+	for name, body := range bad {
+		if _, err := LoadGenesisConfig(writeGenesis(t, body)); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// Hash function a chain seals its block headers with
+type Hasher interface {
+	Sum(data []byte) []byte
+}
+
+// Adapts a plain function to the Hasher interface
+type HasherFunc func(data []byte) []byte
+
+func (f HasherFunc) Sum(data []byte) []byte { return f(data) }
+
+const defaultHasherName = "sha256"
+
+// Hashers selectable by name through GenesisConfig.Hash
+var hashers = map[string]Hasher{
+	"sha256": HasherFunc(func(data []byte) []byte {
+		sum := sha256.Sum256(data)
+		return sum[:]
+	}),
+	"sha3-256": HasherFunc(func(data []byte) []byte {
+		sum := sha3.Sum256(data)
+		return sum[:]
+	}),
+	"blake2b": HasherFunc(func(data []byte) []byte {
+		sum := blake2b.Sum256(data)
+		return sum[:]
+	}),
+}
+
+// The active chain's header hasher
+var blockHasher = hashers[defaultHasherName]
+
+// Makes h available under name, replacing any hasher already registered there
+func RegisterHasher(name string, h Hasher) {
+	hashers[name] = h
+}
+
+// Registered hasher for name; the default when name is empty
+func HasherByName(name string) (Hasher, error) {
+	if name == "" {
+		name = defaultHasherName
+	}
+	h, ok := hashers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown hash %q (have %v)", name, hasherNames())
+	}
+	return h, nil
+}
+
+func hasherNames() []string {
+	var names []string
+	for name := range hashers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func TestRegisteredHasherChangesBlockHashes(t *testing.T) {
+	defer UseTestDifficulty()()
+	RegisterHasher("salted-sha256", HasherFunc(func(data []byte) []byte {
+		sum := sha256.Sum256(append([]byte("salt|"), data...))
+		return sum[:]
+	}))
+	defer delete(hashers, "salted-sha256")
+
+	h, err := HasherByName("salted-sha256")
+	if err != nil || h == nil {
+		t.Fatalf("lookup of the registered hasher: %v", err)
+	}
+	if _, err := HasherByName("md4"); err == nil {
+		t.Fatal("unknown hasher name resolved")
+	}
+
+	plain := NewChain(GenesisConfig{ShardCount: 1})
+	salted := NewChain(GenesisConfig{ShardCount: 1, Hash: "salted-sha256"})
+	if plain.forest[0].Blocks[0].Hash == salted.forest[0].Blocks[0].Hash {
+		t.Fatal("genesis hashes the same under a different hasher")
+	}
+	block := Block{BlockBody: BlockBody{Data: "same block"}}
+	defaultHash := calculateHash(block)
+	salted.Do(func() {
+		if calculateHash(block) == defaultHash {
+			t.Error("the selected hasher did not change the block hash")
+		}
+		if err := addBlockToShards("under salted hash", "Validator1"); err != nil {
+			t.Fatal(err)
+		}
+		MustValidate(t, merkleForest)
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+func dataHash(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// Header with TxRoot and DataHash recomputed from the block's body
+func (b Block) sealedHeader() BlockHeader {
+	h := b.BlockHeader
+	h.TxRoot = transactionsRoot(b.Transactions)
+	h.DataHash = dataHash(b.Data)
+	return h
+}
+
+// Fixes the body roots into the header and sets the hash; called once the nonce is final
+func (b *Block) seal() {
+	b.BlockHeader = b.sealedHeader()
+	b.Hash = hashHeader(b.BlockHeader)
+}
+
+// seal for the chain the proposal was built against
+func (b *Block) sealFor(chain ProposalChain) {
+	b.BlockHeader = b.sealedHeader()
+	b.Hash = hashHeaderFor(b.BlockHeader, chain.ID, chain.Hasher)
+}
+
+// Reports whether this body is the one h commits to
+func (body BlockBody) Matches(h BlockHeader) bool {
+	return transactionsRoot(body.Transactions) == h.TxRoot && dataHash(body.Data) == h.DataHash
+}
+
+// Checks a header on its own: the hash covers its fields and meets the proof of work
+func verifyHeader(h BlockHeader) error {
+	if !hashesEqual(h.Hash, hashHeader(h)) {
+		return fmt.Errorf("header %d: hash mismatch", h.Index)
+	}
+	if !hasLeadingZeroBits(h.Hash, difficultyBits) {
+		return fmt.Errorf("header %d: insufficient proof of work", h.Index)
+	}
+	return nil
+}
+
+// Verifies a run of headers and their PrevHash links, as a header-first sync would before fetching bodies
+func verifyHeaderChain(headers []BlockHeader) error {
+	for i, h := range headers {
+		if err := verifyHeader(h); err != nil {
+			return err
+		}
+		if i > 0 && (h.PrevHash != headers[i-1].Hash || h.Index != headers[i-1].Index+1) {
+			return fmt.Errorf("header %d: does not extend header %d", h.Index, headers[i-1].Index)
+		}
+	}
+	return nil
+}
+
+// Headers of a shard's blocks, in order
+func shardHeaders(shardIndex int) []BlockHeader {
+	if shardIndex < 0 || shardIndex >= len(merkleForest) {
+		return nil
+	}
+	var headers []BlockHeader
+	for _, block := range merkleForest[shardIndex].Blocks {
+		headers = append(headers, block.BlockHeader)
+	}
+	return headers
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestHeadersVerifyWithoutBodies(t *testing.T) {
+	defer UseTestDifficulty()()
+	shard := NewTestShard(0, 3)
+	tip := MineTestBlockWith(shard.Blocks[2], Transaction{From: "alice", To: "bob", Amount: 5})
+	blocks := append(shard.Blocks, tip)
+
+	var headers []BlockHeader
+	for _, block := range blocks {
+		headers = append(headers, block.BlockHeader)
+	}
+	if err := verifyHeaderChain(headers); err != nil {
+		t.Fatal(err)
+	}
+	for i, block := range blocks {
+		if !block.BlockBody.Matches(headers[i]) {
+			t.Fatalf("body %d does not match its own header", i)
+		}
+	}
+
+	swapped := tip.BlockBody
+	swapped.Transactions = []Transaction{{From: "alice", To: "mallory", Amount: 5}}
+	if swapped.Matches(tip.BlockHeader) {
+		t.Fatal("a body with different transactions matched the header")
+	}
+	if blocks[2].BlockBody.Matches(tip.BlockHeader) {
+		t.Fatal("another block's body matched the header")
+	}
+
+	tampered := append([]BlockHeader(nil), headers...)
+	tampered[3].TxRoot = blocks[2].TxRoot // the hash commits to the tx root
+	if verifyHeaderChain(tampered) == nil {
+		t.Fatal("header with a replaced tx root verified")
+	}
+	if verifyHeaderChain([]BlockHeader{headers[0], headers[2]}) == nil {
+		t.Fatal("headers with a gap verified as a chain")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// k-ary Merkle trees: each parent hashes up to `arity` children, a short group is padded
+// by repeating its last child. Arity 2 gives exactly the binary tree used for shard roots.
+
+// Children of the group starting at i, padded to arity
+func karyGroup(level []string, i, arity int) []string {
+	group := make([]string, arity)
+	for j := 0; j < arity; j++ {
+		if i+j < len(level) {
+			group[j] = level[i+j]
+		} else {
+			group[j] = group[j-1]
+		}
+	}
+	return group
+}
+
+func hashGroup(group []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(group, "")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Parent level: one hash per group of arity children
+func karyLevel(level []string, arity int) []string {
+	var next []string
+	for i := 0; i < len(level); i += arity {
+		next = append(next, hashGroup(karyGroup(level, i, arity)))
+	}
+	return next
+}
+
+// Root of a k-ary tree over the leaf hashes
+func merkleRootKary(hashes []string, arity int) string {
+	if len(hashes) == 0 || arity < 2 {
+		return ""
+	}
+	level := hashes
+	for len(level) > 1 {
+		level = karyLevel(level, arity)
+	}
+	return level[0]
+}
+
+// Per-level sibling lists (arity-1 hashes each, in group order without the proven node)
+func merkleProofKary(hashes []string, index, arity int) [][]string {
+	if index < 0 || index >= len(hashes) || arity < 2 {
+		return nil
+	}
+	level := hashes
+	var proof [][]string
+	for len(level) > 1 {
+		start := index - index%arity
+		group := karyGroup(level, start, arity)
+		siblings := append(append([]string{}, group[:index%arity]...), group[index%arity+1:]...)
+		proof = append(proof, siblings)
+
+		level = karyLevel(level, arity)
+		index /= arity
+	}
+	return proof
+}
+
+// Rebuilds each group with the running hash at its position and compares the result to root
+func verifyMerkleProofKary(leaf string, index int, proof [][]string, root string, arity int) bool {
+	hash := leaf
+	for _, siblings := range proof {
+		if len(siblings) != arity-1 {
+			return false
+		}
+		pos := index % arity
+		group := append(append(append([]string{}, siblings[:pos]...), hash), siblings[pos:]...)
+		hash = hashGroup(group)
+		index /= arity
+	}
+	return hashesEqual(hash, root)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func leafHashes(n int) []string {
+	hashes := make([]string, n)
+	for i := range hashes {
+		hashes[i] = hashGroup([]string{fmt.Sprint("leaf ", i)})
+	}
+	return hashes
+}
+
+func TestKaryProofsVerify(t *testing.T) {
+	for _, arity := range []int{2, 4, 8} {
+		for _, n := range []int{1, 5, 16, 37} {
+			hashes := leafHashes(n)
+			root := merkleRootKary(hashes, arity)
+			for i, leaf := range hashes {
+				proof := merkleProofKary(hashes, i, arity)
+				if !verifyMerkleProofKary(leaf, i, proof, root, arity) {
+					t.Fatalf("arity %d, %d leaves: proof for leaf %d failed", arity, n, i)
+				}
+				if n > 1 && verifyMerkleProofKary(hashes[(i+1)%n], i, proof, root, arity) {
+					t.Fatalf("arity %d, %d leaves: proof for leaf %d accepted another leaf", arity, n, i)
+				}
+			}
+		}
+	}
+}
+
+func TestFourAryProofIsShorter(t *testing.T) {
+	hashes := leafHashes(64)
+	binary, quad := merkleProofKary(hashes, 9, 2), merkleProofKary(hashes, 9, 4)
+	if len(binary) != 6 || len(quad) != 3 {
+		t.Fatalf("proof depths %d and %d, want 6 levels binary and 3 for 4-ary", len(binary), len(quad))
+	}
+	if len(quad) >= len(binary) {
+		t.Fatal("4-ary proof is not shorter than the binary one")
+	}
+}
+
+func TestArityTwoMatchesBinaryProofs(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 7, 8} {
+		hashes := leafHashes(n)
+		for i := range hashes {
+			var flat []string
+			for _, siblings := range merkleProofKary(hashes, i, 2) {
+				flat = append(flat, siblings...)
+			}
+			if fmt.Sprint(flat) != fmt.Sprint(merkleProofOfHashes(hashes, i)) {
+				t.Fatalf("%d leaves, leaf %d: arity 2 proof differs from the binary proof", n, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Secret key seeds of the validators this node signs for. A seed comes from crypto/rand the
+// first time its validator needs a key, or from the key file given to OpenKeyring; nothing in a
+// validator's public profile leads back to it. Every signing key is derived from the seed.
+type keyStore struct {
+	mu    sync.Mutex
+	seeds map[string][]byte
+}
+
+var keyring = newKeyStore()
+
+func newKeyStore() *keyStore {
+	return &keyStore{seeds: make(map[string][]byte)}
+}
+
+const keySeedSize = 32
+
+// Seed for id, generated on first use
+func validatorSeed(id string) ([]byte, error) {
+	keyring.mu.Lock()
+	defer keyring.mu.Unlock()
+	if seed, ok := keyring.seeds[id]; ok {
+		return seed, nil
+	}
+	seed := make([]byte, keySeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, fmt.Errorf("generate key for %s: %w", id, err)
+	}
+	keyring.seeds[id] = seed
+	return seed, nil
+}
+
+// Key material for one purpose, kept apart from the other keys derived from the same seed
+func deriveKey(seed []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, seed)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// Public keys for the seed this node holds for id: the ed25519 verification key and the
+// compressed BLS key. A seed is generated if there is none yet.
+func localPublicKeys(id string) (verifyKey, blsKey []byte, err error) {
+	seed, err := validatorSeed(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	verifyKey = ed25519SigningKey(seed).Public().(ed25519.PublicKey)
+	key, err := blsSigningKey(seed)
+	if err != nil {
+		return nil, nil, err
+	}
+	blsKey, err = key.PublicKey().MarshalBinary()
+	return verifyKey, blsKey, err
+}
+
+// Gives a validator registered without public keys the ones for this node's seed, as when the
+// node registers a validator it signs for. Keys already on the profile are kept: those came from
+// the validator itself, and other nodes check its signatures against them.
+func registerKeys(id string, v *ValidatorProfile) error {
+	if len(v.VerifyKey) > 0 && len(v.BLSKey) > 0 {
+		return nil
+	}
+	verifyKey, blsKey, err := localPublicKeys(id)
+	if err != nil {
+		return err
+	}
+	if len(v.VerifyKey) == 0 {
+		v.VerifyKey = verifyKey
+	}
+	if len(v.BLSKey) == 0 {
+		v.BLSKey = blsKey
+	}
+	return nil
+}
+
+// set, with registerKeys applied to every validator in it
+func withLocalKeys(set map[string]*ValidatorProfile) map[string]*ValidatorProfile {
+	for id, v := range set {
+		if err := registerKeys(id, v); err != nil {
+			panic(err)
+		}
+	}
+	return set
+}
+
+// Loads validator seeds from path, creating the file if it doesn't exist, then generates seeds
+// for any of ids still without one and writes them back. The file is readable by its owner only.
+func OpenKeyring(path string, ids []string) error {
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		var stored map[string]string
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return fmt.Errorf("keyring %s: %w", path, err)
+		}
+		keyring.mu.Lock()
+		for id, h := range stored {
+			seed, err := hex.DecodeString(h)
+			if err != nil || len(seed) != keySeedSize {
+				keyring.mu.Unlock()
+				return fmt.Errorf("keyring %s: bad seed for %s", path, id)
+			}
+			keyring.seeds[id] = seed
+		}
+		keyring.mu.Unlock()
+	}
+
+	for _, id := range ids {
+		if _, err := validatorSeed(id); err != nil {
+			return err
+		}
+	}
+	// The file's seeds replace any generated earlier in this run, so the validators this node
+	// signs for take the public keys matching them
+	consensusMu.Lock()
+	for _, id := range ids {
+		if v, ok := validators[id]; ok {
+			v.VerifyKey, v.BLSKey = nil, nil
+			if err := registerKeys(id, v); err != nil {
+				consensusMu.Unlock()
+				return err
+			}
+		}
+	}
+	consensusMu.Unlock()
+	keyring.mu.Lock()
+	stored := make(map[string]string, len(keyring.seeds))
+	for id, seed := range keyring.seeds {
+		stored[id] = hex.EncodeToString(seed)
+	}
+	keyring.mu.Unlock()
+	data, err = json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Registered validator ids in sorted order, for OpenKeyring
+func registeredValidatorIDs() []string {
+	consensusMu.RLock()
+	defer consensusMu.RUnlock()
+	return sortedValidatorIDs()
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// Transaction transfers Amount from one account to another.
+// Nonce must equal the sender's count of previously applied transactions, so a replay is rejected.
+// Gas is the declared compute cost, bounded per block by blockGasLimit; Fee sets mempool priority.
+type Transaction struct {
+	From   string
+	To     string
+	Amount uint64
+	Nonce  uint64
+	Gas    uint64
+	Fee    uint64
+
+	ExpiryHeight int // highest block index that may include it; 0 never expires
+}
+
+// Hash over a canonical encoding: account names are length-prefixed and every field is
+// delimited, so no two distinct transactions share a record
+func (tx Transaction) Hash() string {
+	record := fmt.Sprintf("tx|%d:%s|%d:%s|%d|%d|%d|%d|%d",
+		len(tx.From), tx.From, len(tx.To), tx.To, tx.Amount, tx.Nonce, tx.Gas, tx.Fee, tx.ExpiryHeight)
+	hash := sha256.Sum256([]byte(record))
+	return hex.EncodeToString(hash[:])
+}
+
+// Whether a block at height is too late to include the transaction
+func (tx Transaction) expiredAt(height int) bool {
+	return tx.ExpiryHeight > 0 && height > tx.ExpiryHeight
+}
+
+// Root over a block's transactions, in block order. It commits to the transaction count as
+// well as the Merkle tree, since an odd node out is paired with itself and the tree alone can't
+// tell a duplicated last leaf from a real extra transaction.
+func transactionsRoot(txs []Transaction) string {
+	var hashes []string
+	for _, tx := range txs {
+		hashes = append(hashes, tx.Hash())
+	}
+	return countedTxRoot(len(hashes), merkleRootOfHashes(hashes))
+}
+
+func countedTxRoot(count int, treeRoot string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("txs|%d|%s", count, treeRoot)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Proof that a transaction sits at a given position among its block's transactions. Root is the
+// Merkle tree's root; the block's TxRoot binds it to TxCount.
+type TxPositionProof struct {
+	MerkleProof
+	TxCount int // transactions in the block, which fixes the tree shape
+}
+
+// Position proof for block.Transactions[txIndex] against the block's transactions root
+func proveTxPosition(block Block, txIndex int) (TxPositionProof, error) {
+	if txIndex < 0 || txIndex >= len(block.Transactions) {
+		return TxPositionProof{}, fmt.Errorf("tx %d out of range (block has %d)", txIndex, len(block.Transactions))
+	}
+	var hashes []string
+	for _, tx := range block.Transactions {
+		hashes = append(hashes, tx.Hash())
+	}
+	return TxPositionProof{
+		MerkleProof: MerkleProof{
+			Leaf:     hashes[txIndex],
+			Index:    txIndex,
+			Siblings: merkleProofOfHashes(hashes, txIndex),
+			Root:     merkleRootOfHashes(hashes),
+		},
+		TxCount: len(hashes),
+	}, nil
+}
+
+// Checks the proof against txRoot, which must commit to both the tree root and TxCount. The index
+// must be below that count and the path exactly as deep as a tree of TxCount leaves, otherwise
+// padding or high index bits could pass for another position.
+func verifyTxPosition(proof TxPositionProof, txRoot string) bool {
+	if proof.Index < 0 || proof.Index >= proof.TxCount || !hashesEqual(countedTxRoot(proof.TxCount, proof.Root), txRoot) {
+		return false
+	}
+	depth := 0
+	for width := proof.TxCount; width > 1; width = (width + 1) / 2 {
+		depth++
+	}
+	return len(proof.Siblings) == depth && proof.Verify()
+}
+
+var blockGasLimit uint64 = 100000 // total gas the transactions of one block may declare
+
+func totalGas(txs []Transaction) uint64 {
+	var gas uint64
+	for _, tx := range txs {
+		gas += tx.Gas
+	}
+	return gas
+}
+
+// State holds the account balances blocks are applied to, plus each sender's next expected nonce
+// and the hashes of the blocks already applied
+type State struct {
+	Balances map[string]uint64
+	Nonces   map[string]uint64
+	Applied  map[string]bool
+}
+
+func newState() *State {
+	return &State{Balances: make(map[string]uint64), Nonces: make(map[string]uint64), Applied: make(map[string]bool)}
+}
+
+func (s *State) clone() *State {
+	applied := make(map[string]bool, len(s.Applied))
+	for hash := range s.Applied {
+		applied[hash] = true
+	}
+	return &State{Balances: copyCounts(s.Balances), Nonces: copyCounts(s.Nonces), Applied: applied}
+}
+
+func copyCounts(m map[string]uint64) map[string]uint64 {
+	c := make(map[string]uint64, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// Replay check: the transaction's nonce must be exactly the sender's next expected value
+func checkNonce(nonces map[string]uint64, tx Transaction) error {
+	if expected := nonces[tx.From]; tx.Nonce != expected {
+		return fmt.Errorf("bad nonce for %s: got %d, want %d", tx.From, tx.Nonce, expected)
+	}
+	return nil
+}
+
+const baseBlockReward uint64 = 50 // proposer credit before the first halving
+
+var rewardHalvingInterval = 100 // blocks between reward halvings
+
+// Proposer credit at a height: the base reward halved every rewardHalvingInterval blocks, flooring at zero
+func blockReward(height int) uint64 {
+	if height < 0 {
+		return 0
+	}
+	halvings := height / rewardHalvingInterval
+	if halvings >= 64 {
+		return 0
+	}
+	return baseBlockReward >> halvings
+}
+
+// Applies a block's transfers in order, then credits the proposer's reward; on any failure the state is left untouched.
+// A block whose hash was already applied is skipped, so replays don't double-count. Unsealed blocks
+// (no hash yet, as in mempool packing) are not tracked.
+func (s *State) applyBlock(block Block) error {
+	if block.Hash != "" && s.Applied[block.Hash] {
+		return nil
+	}
+	balances := copyCounts(s.Balances)
+	nonces := copyCounts(s.Nonces)
+	for i, tx := range block.Transactions {
+		balance, ok := balances[tx.From]
+		if !ok {
+			return fmt.Errorf("tx %d: unknown account %s", i, tx.From)
+		}
+		if err := checkNonce(nonces, tx); err != nil {
+			return fmt.Errorf("tx %d: %w", i, err)
+		}
+		if balance < tx.Amount {
+			return fmt.Errorf("tx %d: insufficient funds in %s", i, tx.From)
+		}
+		balances[tx.From] = balance - tx.Amount
+		balances[tx.To] += tx.Amount
+		nonces[tx.From]++
+	}
+	if block.Validator != "" {
+		balances[block.Validator] += blockReward(block.Index)
+	}
+	s.Balances = balances
+	s.Nonces = nonces
+	if block.Hash != "" {
+		if s.Applied == nil {
+			s.Applied = make(map[string]bool)
+		}
+		s.Applied[block.Hash] = true
+	}
+	return nil
+}
+
+// Leaf hash committing to one account's balance and nonce
+func accountLeaf(account string, balance, nonce uint64) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%d", account, balance, nonce)))
+	return hex.EncodeToString(hash[:])
+}
+
+// Account names in leaf order
+func (s *State) accounts() []string {
+	var names []string
+	for account := range s.Balances {
+		names = append(names, account)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *State) leaves() []string {
+	var leaves []string
+	for _, account := range s.accounts() {
+		leaves = append(leaves, accountLeaf(account, s.Balances[account], s.Nonces[account]))
+	}
+	return leaves
+}
+
+// Merkle root over account leaves sorted by account name
+func (s *State) Root() string {
+	return merkleRootOfHashes(s.leaves())
+}
+
+// Leaf index and Merkle proof for one account
+func (s *State) proveAccount(account string) (int, []string, bool) {
+	index := sort.SearchStrings(s.accounts(), account)
+	if _, ok := s.Balances[account]; !ok {
+		return 0, nil, false
+	}
+	return index, merkleProofOfHashes(s.leaves(), index), true
+}
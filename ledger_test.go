@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTransactionHashIsCanonical(t *testing.T) {
+	pairs := [][2]Transaction{
+		{{From: "ab", To: "c", Amount: 1}, {From: "a", To: "bc", Amount: 1}},
+		{{From: "a", To: "b", Amount: 12, Nonce: 3}, {From: "a", To: "b", Amount: 1, Nonce: 23}},
+		{{From: "a|1", To: "b"}, {From: "a", To: "1|b"}},
+		{{From: "a", To: "b", Gas: 1}, {From: "a", To: "b", Fee: 1}},
+	}
+	for _, pair := range pairs {
+		if pair[0].Hash() == pair[1].Hash() {
+			t.Errorf("%+v and %+v share hash %s", pair[0], pair[1], pair[0].Hash())
+		}
+	}
+	tx := Transaction{From: "a", To: "b", Amount: 7, Nonce: 1, Gas: 2, Fee: 3, ExpiryHeight: 9}
+	if tx.Hash() != tx.Hash() {
+		t.Fatal("hash is not deterministic")
+	}
+}
+
+func TestStaleNonceIsRejected(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1, Balances: map[string]uint64{"alice": 100}})
+	tip := func() Block { return merkleForest[0].Blocks[len(merkleForest[0].Blocks)-1] }
+	send := func(nonce uint64) error {
+		return acceptBlock(0, MineTestBlockWith(tip(), Transaction{From: "alice", To: "bob", Amount: 1, Nonce: nonce}))
+	}
+
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		if err := send(nonce); err != nil {
+			t.Fatalf("nonce %d: %v", nonce, err)
+		}
+	}
+	for _, stale := range []uint64{0, 2, 4} {
+		if err := send(stale); err == nil {
+			t.Fatalf("nonce %d accepted, want only 3", stale)
+		}
+	}
+	if ledger.Nonces["alice"] != 3 || ledger.Balances["bob"] != 3 {
+		t.Fatalf("alice nonce %d, bob balance %d after three transfers", ledger.Nonces["alice"], ledger.Balances["bob"])
+	}
+}
+
+func TestBlockRewardHalvesAndFloorsAtZero(t *testing.T) {
+	previous := rewardHalvingInterval
+	defer func() { rewardHalvingInterval = previous }()
+	rewardHalvingInterval = 10
+
+	cases := []struct {
+		height int
+		want   uint64
+	}{
+		{0, baseBlockReward},
+		{9, baseBlockReward},
+		{10, baseBlockReward / 2},
+		{19, baseBlockReward / 2},
+		{20, baseBlockReward / 4},
+		{10 * 6, 0}, // 50 >> 6
+		{10 * 64, 0},
+		{10 * 1000, 0},
+		{-1, 0},
+	}
+	for _, c := range cases {
+		if got := blockReward(c.height); got != c.want {
+			t.Errorf("blockReward(%d) = %d, want %d", c.height, got, c.want)
+		}
+	}
+}
+
+func TestProposerIsCreditedTheScheduledReward(t *testing.T) {
+	state := newState()
+	previous := rewardHalvingInterval
+	defer func() { rewardHalvingInterval = previous }()
+	rewardHalvingInterval = 10
+
+	for _, height := range []int{1, 12} {
+		if err := state.applyBlock(Block{BlockHeader: BlockHeader{Index: height, Validator: "miner"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := state.Balances["miner"]; got != baseBlockReward+baseBlockReward/2 {
+		t.Fatalf("miner balance %d, want %d", got, baseBlockReward+baseBlockReward/2)
+	}
+}
+
+func TestTxPositionProofs(t *testing.T) {
+	defer UseTestDifficulty()()
+	var txs []Transaction
+	for i := uint64(0); i < 5; i++ {
+		txs = append(txs, Transaction{From: "alice", To: "bob", Amount: 1, Nonce: i})
+	}
+	block := MineTestBlockWith(MineTestBlock("genesis"), txs...)
+
+	for i := range txs {
+		proof, err := proveTxPosition(block, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !verifyTxPosition(proof, block.TxRoot) {
+			t.Fatalf("proof for tx %d failed against the block's TxRoot", i)
+		}
+		wrong := proof
+		wrong.Index = (i + 1) % len(txs)
+		if verifyTxPosition(wrong, block.TxRoot) {
+			t.Fatalf("tx %d verified at position %d", i, wrong.Index)
+		}
+	}
+	if _, err := proveTxPosition(block, len(txs)); err == nil {
+		t.Fatal("proof built for a position past the last tx")
+	}
+}
+
+// The last of an odd number of txs is paired with itself, so its path also fits the padding slot
+func TestTxPositionProofRejectsForgedPaddingIndex(t *testing.T) {
+	txs := []Transaction{{From: "a", Nonce: 0}, {From: "a", Nonce: 1}, {From: "a", Nonce: 2}}
+	block := Block{BlockBody: BlockBody{Transactions: txs}}
+	root := transactionsRoot(txs)
+	proof, err := proveTxPosition(block, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged := proof
+	forged.Index = 3
+	if verifyTxPosition(forged, root) {
+		t.Fatal("padding slot accepted as position 3")
+	}
+	forged.TxCount = 4
+	if verifyTxPosition(forged, root) {
+		t.Fatal("inflated TxCount accepted against the counted root")
+	}
+	deep := proof
+	deep.Index = 2 + 4 // a high index bit beyond the tree
+	deep.Siblings = append(append([]string(nil), proof.Siblings...), proof.Root)
+	if verifyTxPosition(deep, root) {
+		t.Fatal("proof padded with an extra level accepted")
+	}
+}
+
+func TestApplyingABlockTwiceCountsOnce(t *testing.T) {
+	block := Block{
+		BlockHeader: BlockHeader{Index: 1, Hash: "applied-once", Validator: "miner"},
+		BlockBody:   BlockBody{Transactions: []Transaction{{From: "alice", To: "bob", Amount: 10}}},
+	}
+	once, twice := newState(), newState()
+	once.Balances["alice"], twice.Balances["alice"] = 100, 100
+
+	if err := once.applyBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := twice.applyBlock(block); err != nil {
+			t.Fatalf("application %d: %v", i, err)
+		}
+	}
+	if fmt.Sprint(twice.Balances) != fmt.Sprint(once.Balances) || fmt.Sprint(twice.Nonces) != fmt.Sprint(once.Nonces) {
+		t.Fatalf("balances %v nonces %v after a replay, want %v and %v", twice.Balances, twice.Nonces, once.Balances, once.Nonces)
+	}
+}
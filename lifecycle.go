@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Lifecycle state of a shard; the zero value is Active
+type ShardState int
+
+const (
+	ShardActive    ShardState = iota // accepts blocks
+	ShardSplitting                   // blocks moving out to a new shard
+	ShardMerging                     // blocks moving in from another shard
+	ShardPruning                     // old blocks being dropped
+	ShardReadOnly                    // serves reads only, until reactivated
+)
+
+func (s ShardState) String() string {
+	switch s {
+	case ShardActive:
+		return "Active"
+	case ShardSplitting:
+		return "Splitting"
+	case ShardMerging:
+		return "Merging"
+	case ShardPruning:
+		return "Pruning"
+	case ShardReadOnly:
+		return "ReadOnly"
+	}
+	return fmt.Sprintf("ShardState(%d)", int(s))
+}
+
+// Allowed transitions: every maintenance state is entered from and returns to Active
+var shardTransitions = map[ShardState][]ShardState{
+	ShardActive:    {ShardSplitting, ShardMerging, ShardPruning, ShardReadOnly},
+	ShardSplitting: {ShardActive},
+	ShardMerging:   {ShardActive},
+	ShardPruning:   {ShardActive},
+	ShardReadOnly:  {ShardActive},
+}
+
+var (
+	errShardBusy     = errors.New("shard busy, retry later") // transient maintenance state
+	errShardReadOnly = errors.New("shard is read-only")
+)
+
+// Moves a shard to state to, rejecting transitions the lifecycle doesn't allow
+func SetShardState(shardIndex int, to ShardState) error {
+	defer lockForestWrite()()
+	if shardIndex < 0 || shardIndex >= len(merkleForest) {
+		return fmt.Errorf("shard %d does not exist", shardIndex)
+	}
+	from := merkleForest[shardIndex].State
+	for _, allowed := range shardTransitions[from] {
+		if allowed == to {
+			merkleForest[shardIndex].State = to
+			return nil
+		}
+	}
+	return fmt.Errorf("shard %d: cannot go from %s to %s", shardIndex, from, to)
+}
+
+// Nil when the shard accepts new blocks; errShardBusy during maintenance, which callers may retry
+func shardWritable(shardIndex int) error {
+	switch state := merkleForest[shardIndex].State; state {
+	case ShardActive:
+		return nil
+	case ShardReadOnly:
+		return fmt.Errorf("shard %d: %w", shardIndex, errShardReadOnly)
+	default:
+		return fmt.Errorf("shard %d is %s: %w", shardIndex, state, errShardBusy)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSplittingShardRejectsBlocksUntilActive(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 2})
+	if err := SetShardState(0, ShardSplitting); err != nil {
+		t.Fatal(err)
+	}
+	err := addBlockToShard(0, "mid-split", "Validator1")
+	if !errors.Is(err, errShardBusy) {
+		t.Fatalf("add to a splitting shard: %v, want errShardBusy", err)
+	}
+	if len(merkleForest[0].Blocks) != 1 {
+		t.Fatal("the rejected block reached the shard")
+	}
+
+	if err := SetShardState(0, ShardMerging); err == nil {
+		t.Fatal("went from Splitting straight to Merging")
+	}
+	if err := SetShardState(0, ShardActive); err != nil {
+		t.Fatal(err)
+	}
+	if err := addBlockToShard(0, "after split", "Validator1"); err != nil {
+		t.Fatalf("retry once Active: %v", err)
+	}
+
+	if err := SetShardState(1, ShardReadOnly); err != nil {
+		t.Fatal(err)
+	}
+	if err := addBlockToShard(1, "read-only", "Validator1"); !errors.Is(err, errShardReadOnly) || errors.Is(err, errShardBusy) {
+		t.Fatalf("add to a read-only shard: %v, want a non-retriable errShardReadOnly", err)
+	}
+}
@@ -1,44 +1,119 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"time"
+	"log"
+	"os"
 )
 
-// Block represents a single block in a shard
+// Block represents a single block in a shard: a header, which the hash covers, and the body
+// it commits to through TxRoot and DataHash
 type Block struct {
+	BlockHeader
+	BlockBody
+
+	// Set by consensus, not covered by the hash
+	MPCVerified bool
+	FastPath    bool             // accepted without MPC on the fast path; MPCVerified stays false
+	Consensus   *ConsensusRecord `json:",omitempty"`
+}
+
+// Everything the block hash covers; headers sync and verify without their bodies
+type BlockHeader struct {
 	Index     int
 	Timestamp string
-	Data      string
 	PrevHash  string
-	Hash      string
+	TxRoot    string // transactionsRoot of the body's transactions
+	DataHash  string // sha256 of the body's Data
 	Nonce     int
+	Bits      int // difficulty the block claims, in leading zero bits of Hash
 	Validator string
+	Encrypted bool // Data holds an AES-GCM payload for the shard key
+	Hash      string
+}
+
+// Block payload, matched to its header by TxRoot and DataHash
+type BlockBody struct {
+	Data         string
+	Transactions []Transaction
 }
 
-// Genesis block for a shard
-func createGenesisBlock() Block {
+// Genesis block for a shard; it depends only on the shard index and genesis time, so it is reproducible across runs
+func createGenesisBlock(shardIndex int) Block {
 	genesis := Block{
-		Index:     0,
-		Timestamp: time.Now().String(),
-		Data:      "Genesis Block",
-		PrevHash:  "",
+		BlockHeader: BlockHeader{Index: 0, Timestamp: formatBlockTime(genesisTime), PrevHash: ""},
+		BlockBody:   BlockBody{Data: fmt.Sprintf("Genesis Block %d", shardIndex)},
+	}
+	stats, err := mineBlock(genesis)
+	if err != nil {
+		log.Fatalf("genesis: %v", err)
 	}
-	genesis.Nonce = mineBlock(genesis)
-	genesis.Hash = calculateHash(genesis)
+	genesis.Nonce, genesis.Bits = stats.Nonce, stats.Bits
+	genesis.seal()
 	return genesis
 }
 
 func main() {
-	initAMQFilters()
-
-	// Initialize shards with genesis blocks
-	for i := 0; i < shardCount; i++ {
-		genesis := createGenesisBlock()
-		merkleForest = append(merkleForest, Shard{
-			Blocks:     []Block{genesis},
-			MerkleRoot: genesis.Hash,
-		})
+	httpAddr := flag.String("http", "", "serve health endpoints on this address after the demo (e.g. :8080)")
+	dbPath := flag.String("db", "", "persist the forest to a BoltDB file at this path")
+	genesisPath := flag.String("genesis", "", "genesis config JSON (shard count, validators, balances)")
+	dotPath := flag.String("dot", "", "write a Graphviz DOT rendering of the forest to this file")
+	tlsCert := flag.String("tls-cert", "", "serve HTTPS with this PEM certificate (needs -tls-key)")
+	tlsKey := flag.String("tls-key", "", "PEM private key for -tls-cert")
+	clientCAs := flag.String("client-ca", "", "PEM CA bundle; block submission then requires a client certificate signed by it")
+	webhookURL := flag.String("webhook", "", "POST a JSON notice to this URL for every accepted block")
+	keysPath := flag.String("keys", "", "validator secret key file; created with fresh keys when missing")
+	blockInterval := flag.Duration("block-interval", 0, "while serving HTTP, produce a block per shard at this interval (e.g. 5s)")
+	flag.Parse()
+
+	genesisConfig := GenesisConfig{ShardCount: shardCount}
+	if *genesisPath != "" {
+		cfg, err := LoadGenesisConfig(*genesisPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		genesisConfig = cfg
+	}
+
+	if *dbPath != "" {
+		boltStore, err := openBoltStore(*dbPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer boltStore.Close()
+		store = boltStore
+	}
+
+	if _, _, err := store.GetRoot(0); err == nil {
+		// Resume from the persisted forest
+		applyGenesisConfig(genesisConfig)
+		if err := LoadValidators(); err != nil && !errors.Is(err, errNotFound) {
+			log.Fatal(err)
+		}
+		if err := LoadForest(); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		// Initialize shards with genesis blocks
+		initForest(genesisConfig)
+		if err := persistForest(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *keysPath != "" {
+		if err := OpenKeyring(*keysPath, registeredValidatorIDs()); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *webhookURL != "" {
+		cfg := defaultWebhookConfig
+		cfg.URL = *webhookURL
+		StartWebhook(context.Background(), cfg)
 	}
 
 	// Add some blocks
@@ -69,6 +144,7 @@ func main() {
 
 	// Synchronize shards to update Merkle roots
 	synchronizeShards()
+	fmt.Println("Forest Root:", ForestRoot())
 
 	// Check AMQ presence
 	hash := merkleForest[0].Blocks[0].Hash
@@ -86,4 +162,18 @@ func main() {
 
 	// Conflict resolution simulation
 	resolveConflicts()
+
+	if *dotPath != "" {
+		if err := os.WriteFile(*dotPath, []byte(ForestToDOT()), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *httpAddr != "" {
+		if *blockInterval > 0 {
+			StartProducer(context.Background(), *blockInterval)
+		}
+		fmt.Println("Serving HTTP on", *httpAddr)
+		log.Fatal(serve(ServerConfig{Addr: *httpAddr, TLSCert: *tlsCert, TLSKey: *tlsKey, ClientCAs: *clientCAs}))
+	}
 }
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestGenesisHashIsReproducible(t *testing.T) {
+	defer UseTestDifficulty()()
+	first := NewChain(GenesisConfig{ShardCount: 2})
+	second := NewChain(GenesisConfig{ShardCount: 2})
+	for i := 0; i < 2; i++ {
+		if first.forest[i].Blocks[0].Hash != second.forest[i].Blocks[0].Hash {
+			t.Fatalf("shard %d genesis differs between two chains from the same config", i)
+		}
+	}
+
+	var again Block
+	first.Do(func() { again = createGenesisBlock(0) })
+	if again.Hash != first.forest[0].Blocks[0].Hash {
+		t.Fatal("createGenesisBlock produced a different hash on a second call")
+	}
+
+	later := NewChain(GenesisConfig{ShardCount: 2, GenesisTime: "2024-01-01T00:00:00Z"})
+	if later.forest[0].Blocks[0].Hash == first.forest[0].Blocks[0].Hash {
+		t.Fatal("a different genesis time gave the same genesis hash")
+	}
+}
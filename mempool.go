@@ -0,0 +1,229 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+)
+
+// Mempool holds transactions waiting to be packed into a block, highest fee first
+// (ties go to the earlier arrival)
+type Mempool struct {
+	entries txHeap
+	seq     int
+	blocks  int // blocks accepted since the mempool was created, the clock for inclusion deadlines
+}
+
+type mempoolEntry struct {
+	tx      Transaction
+	seq     int // arrival order
+	addedAt int // value of Mempool.blocks on arrival
+	index   int // position in the heap
+}
+
+// Blocks a transaction may wait before the next block is required to include it
+var inclusionDelay = 3
+
+// txHeap implements heap.Interface as a max-heap on fee
+type txHeap []*mempoolEntry
+
+func (h txHeap) Len() int { return len(h) }
+
+func (h txHeap) Less(i, j int) bool {
+	if h[i].tx.Fee != h[j].tx.Fee {
+		return h[i].tx.Fee > h[j].tx.Fee
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h txHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *txHeap) Push(x any) {
+	entry := x.(*mempoolEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *txHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+var mempool = &Mempool{}
+
+func (m *Mempool) Add(tx Transaction) {
+	heap.Push(&m.entries, &mempoolEntry{tx: tx, seq: m.seq, addedAt: m.blocks})
+	m.seq++
+}
+
+func (m *Mempool) Len() int {
+	return m.entries.Len()
+}
+
+// Advances the inclusion clock; called once per accepted block
+func (m *Mempool) blockAccepted() {
+	m.blocks++
+}
+
+// Drops the pending transactions with the given ids, returning how many were removed
+func (m *Mempool) Remove(ids []string) int {
+	drop := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		drop[id] = true
+	}
+	removed := 0
+	for i := m.entries.Len() - 1; i >= 0; i-- {
+		if drop[m.entries[i].tx.Hash()] {
+			heap.Remove(&m.entries, i)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Runs after a block is committed, before subscribers hear about it
+type AcceptHook func(shardIndex int, block Block)
+
+// Hooks run in registration order; mempool eviction and the expiry sweep come first
+var acceptHooks = []AcceptHook{evictIncluded, sweepExpired}
+
+// Adds a post-accept hook, run after the existing ones
+func RegisterAcceptHook(h AcceptHook) {
+	acceptHooks = append(acceptHooks, h)
+}
+
+func runAcceptHooks(shardIndex int, block Block) {
+	for _, h := range acceptHooks {
+		h(shardIndex, block)
+	}
+}
+
+// Removes the block's transactions from the mempool so they aren't packed again
+func evictIncluded(shardIndex int, block Block) {
+	ids := make([]string, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		ids[i] = tx.Hash()
+	}
+	mempool.Remove(ids)
+}
+
+// Drops transactions that a block at height could no longer include, returning how many
+func (m *Mempool) Sweep(height int) int {
+	var expired []string
+	for _, entry := range m.entries {
+		if entry.tx.expiredAt(height) {
+			expired = append(expired, entry.tx.Hash())
+		}
+	}
+	return m.Remove(expired)
+}
+
+// TTL sweep: once the lowest next height across shards passes a transaction's expiry, no shard
+// can include it any more
+func sweepExpired(shardIndex int, block Block) {
+	next := -1
+	for _, shard := range merkleForest {
+		if len(shard.Blocks) == 0 {
+			continue
+		}
+		if h := shard.Blocks[len(shard.Blocks)-1].Index + 1; next == -1 || h < next {
+			next = h
+		}
+	}
+	if n := mempool.Sweep(next); n > 0 {
+		fmt.Printf("Mempool: %d expired transactions evicted\n", n)
+	}
+}
+
+// Pending entries in priority order; the heap itself is left untouched
+func (m *Mempool) orderedEntries() []*mempoolEntry {
+	entries := append(txHeap(nil), m.entries...)
+	sort.Slice(entries, func(i, j int) bool { return entries.Less(i, j) })
+	return entries
+}
+
+// All pending transactions in priority order
+func (m *Mempool) ordered() []Transaction {
+	var txs []Transaction
+	for _, entry := range m.orderedEntries() {
+		txs = append(txs, entry.tx)
+	}
+	return txs
+}
+
+// Pending transactions that have waited inclusionDelay blocks or more and are still valid at
+// height, in priority order
+func (m *Mempool) overdue(height int) []Transaction {
+	var txs []Transaction
+	for _, entry := range m.orderedEntries() {
+		if m.blocks-entry.addedAt >= inclusionDelay && !entry.tx.expiredAt(height) {
+			txs = append(txs, entry.tx)
+		}
+	}
+	return txs
+}
+
+// Overdue transactions a block at height must carry: those that apply cleanly on the ledger
+// and fit in the gas limit. Ones that can't apply are not held against the proposer.
+func inclusionList(height int) []Transaction {
+	var gas uint64
+	return selectApplicable(ledger.clone(), mempool.overdue(height), blockGasLimit, &gas)
+}
+
+// The n highest-fee pending transactions
+func (m *Mempool) Top(n int) []Transaction {
+	txs := m.ordered()
+	if n < len(txs) {
+		txs = txs[:n]
+	}
+	return txs
+}
+
+// Selects pending transactions for a block at height that apply cleanly on top of state,
+// staying within gasLimit: the inclusion list first, then the rest by fee. Transactions that
+// don't fit or don't apply yet are skipped and stay pending; expired ones are left out.
+func (m *Mempool) Pack(state *State, gasLimit uint64, height int) []Transaction {
+	scratch := state.clone()
+	var gas uint64
+	packed := selectApplicable(scratch, m.overdue(height), gasLimit, &gas)
+
+	included := make(map[string]bool, len(packed))
+	for _, tx := range packed {
+		included[tx.Hash()] = true
+	}
+	var rest []Transaction
+	for _, tx := range m.ordered() {
+		if !included[tx.Hash()] && !tx.expiredAt(height) {
+			rest = append(rest, tx)
+		}
+	}
+	return append(packed, selectApplicable(scratch, rest, gasLimit, &gas)...)
+}
+
+// Applies candidates to scratch in order while they fit under gasLimit, adding to *gas.
+// Passes repeat so a sender's later nonce can follow an earlier one selected in the same block.
+func selectApplicable(scratch *State, candidates []Transaction, gasLimit uint64, gas *uint64) []Transaction {
+	var selected []Transaction
+	remaining := candidates
+	for progress := true; progress; {
+		progress = false
+		var skipped []Transaction
+		for _, tx := range remaining {
+			if *gas+tx.Gas > gasLimit || scratch.applyBlock(Block{BlockBody: BlockBody{Transactions: []Transaction{tx}}}) != nil {
+				skipped = append(skipped, tx)
+				continue
+			}
+			selected = append(selected, tx)
+			*gas += tx.Gas
+			progress = true
+		}
+		remaining = skipped
+	}
+	return selected
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPackStaysWithinGasLimit(t *testing.T) {
+	state := newState()
+	state.Balances["alice"], state.Balances["carol"] = 100, 100
+	m := &Mempool{}
+	for nonce := uint64(0); nonce < 4; nonce++ {
+		m.Add(Transaction{From: "alice", To: "bob", Amount: 1, Nonce: nonce, Gas: 4, Fee: 1})
+	}
+	m.Add(Transaction{From: "carol", To: "bob", Amount: 1, Gas: 3, Fee: 5})
+
+	packed := m.Pack(state, 10, 1)
+	if gas := totalGas(packed); gas > 10 {
+		t.Fatalf("packed %d gas under a limit of 10", gas)
+	}
+	if len(packed) != 2 || packed[0].From != "carol" || packed[1].Nonce != 0 {
+		t.Fatalf("packed %+v, want carol's high-fee transfer then alice's first", packed)
+	}
+	if m.Len() != 5 {
+		t.Fatalf("%d transactions pending, want all 5 left in the pool", m.Len())
+	}
+}
+
+func TestTopReturnsHighestFeesFirst(t *testing.T) {
+	m := &Mempool{}
+	for _, fee := range []uint64{3, 9, 1, 7, 5, 7} {
+		m.Add(Transaction{From: "alice", To: "bob", Fee: fee, Amount: fee})
+	}
+
+	top := m.Top(4)
+	var fees []uint64
+	for _, tx := range top {
+		fees = append(fees, tx.Fee)
+	}
+	if fmt.Sprint(fees) != "[9 7 7 5]" {
+		t.Fatalf("top fees %v, want [9 7 7 5]", fees)
+	}
+	if len(m.Top(10)) != 6 || m.Len() != 6 {
+		t.Fatal("Top consumed or lost pending transactions")
+	}
+}
+
+func TestAcceptedBlockEvictsItsTransactions(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1, Balances: map[string]uint64{"alice": 100, "carol": 100}})
+	first := Transaction{From: "alice", To: "bob", Amount: 1, Nonce: 0}
+	second := Transaction{From: "alice", To: "bob", Amount: 1, Nonce: 1}
+	other := Transaction{From: "carol", To: "bob", Amount: 2, Nonce: 0}
+	for _, tx := range []Transaction{first, second, other} {
+		mempool.Add(tx)
+	}
+
+	previousHooks := acceptHooks
+	defer func() { acceptHooks = previousHooks }()
+	var heard []int
+	RegisterAcceptHook(func(_ int, block Block) { heard = append(heard, len(block.Transactions)) })
+
+	block := MineTestBlockWith(merkleForest[0].Blocks[0], first, other)
+	if err := ImportBlock(0, block); err != nil {
+		t.Fatal(err)
+	}
+	pending := mempool.ordered()
+	if len(pending) != 1 || pending[0].Hash() != second.Hash() {
+		t.Fatalf("pending after the block: %+v, want only alice's second transfer", pending)
+	}
+	if fmt.Sprint(heard) != "[2]" {
+		t.Fatalf("registered hook saw %v", heard)
+	}
+}
+
+func TestExpiredTransactionIsSweptAndRejected(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1, Balances: map[string]uint64{"alice": 100, "carol": 100}})
+	expiring := Transaction{From: "alice", To: "bob", Amount: 1, ExpiryHeight: 1}
+	lasting := Transaction{From: "alice", To: "bob", Amount: 1, Nonce: 1}
+	mempool.Add(expiring)
+	mempool.Add(lasting)
+
+	// A block at height 1 can still include it, but this one carries carol's transfer instead
+	first := MineTestBlockWith(merkleForest[0].Blocks[0], Transaction{From: "carol", To: "bob", Amount: 1})
+	if err := ImportBlock(0, first); err != nil {
+		t.Fatal(err)
+	}
+	pending := mempool.ordered()
+	if len(pending) != 1 || pending[0].Hash() != lasting.Hash() {
+		t.Fatalf("pending past height 1: %+v, want only the unexpiring transfer", pending)
+	}
+
+	late := MineTestBlockWith(first, expiring)
+	if err := ImportBlock(0, late); err == nil {
+		t.Fatal("block at height 2 carrying a transaction that expired at 1 accepted")
+	}
+	if err := validateTxExpiry(MineTestBlockWith(merkleForest[0].Blocks[0], expiring), merkleForest[0]); err != nil {
+		t.Fatalf("block at the expiry height itself: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const defaultAMQAlertRate = 0.01
+
+// False positive rate at which an AMQ filter counts as saturated and should be rebuilt larger
+var amqAlertRate = defaultAMQAlertRate
+
+// Sets the saturation alert threshold; a rate outside (0, 1] restores the default
+func SetAMQAlertRate(rate float64) {
+	if rate <= 0 || rate > 1 {
+		rate = defaultAMQAlertRate
+	}
+	amqAlertRate = rate
+}
+
+// Saturation of one shard's AMQ filter
+type AMQStat struct {
+	Shard             int
+	FillRatio         float64
+	FalsePositiveRate float64
+	Alert             bool // FalsePositiveRate has reached amqAlertRate
+}
+
+func AMQStats() []AMQStat {
+	stats := make([]AMQStat, len(amqFilters))
+	for i, f := range amqFilters {
+		rate := f.FalsePositiveRate()
+		stats[i] = AMQStat{Shard: i, FillRatio: f.FillRatio(), FalsePositiveRate: rate, Alert: rate >= amqAlertRate}
+	}
+	return stats
+}
+
+// Prometheus text exposition of AMQ saturation and proof cache counters
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	forestMu.RLock()
+	amq := AMQStats()
+	forestMu.RUnlock()
+	proofs := ProofCacheStats()
+
+	var b strings.Builder
+	fmt.Fprintln(&b, "# HELP amq_false_positive_rate Estimated false positive rate of each shard's AMQ filter.")
+	fmt.Fprintln(&b, "# TYPE amq_false_positive_rate gauge")
+	for _, s := range amq {
+		fmt.Fprintf(&b, "amq_false_positive_rate{shard=\"%d\"} %g\n", s.Shard, s.FalsePositiveRate)
+	}
+	fmt.Fprintln(&b, "# HELP amq_fill_ratio Share of each shard's AMQ filter bits that are set.")
+	fmt.Fprintln(&b, "# TYPE amq_fill_ratio gauge")
+	for _, s := range amq {
+		fmt.Fprintf(&b, "amq_fill_ratio{shard=\"%d\"} %g\n", s.Shard, s.FillRatio)
+	}
+	fmt.Fprintln(&b, "# HELP amq_saturated 1 when the shard's AMQ false positive rate has reached the alert threshold.")
+	fmt.Fprintln(&b, "# TYPE amq_saturated gauge")
+	for _, s := range amq {
+		alert := 0
+		if s.Alert {
+			alert = 1
+		}
+		fmt.Fprintf(&b, "amq_saturated{shard=\"%d\"} %d\n", s.Shard, alert)
+	}
+	fmt.Fprintln(&b, "# HELP amq_alert_rate Configured AMQ saturation alert threshold.")
+	fmt.Fprintln(&b, "# TYPE amq_alert_rate gauge")
+	fmt.Fprintf(&b, "amq_alert_rate %g\n", amqAlertRate)
+	fmt.Fprintln(&b, "# TYPE proof_cache_hits_total counter")
+	fmt.Fprintf(&b, "proof_cache_hits_total %d\n", proofs.Hits)
+	fmt.Fprintln(&b, "# TYPE proof_cache_misses_total counter")
+	fmt.Fprintf(&b, "proof_cache_misses_total %d\n", proofs.Misses)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write([]byte(b.String()))
+}
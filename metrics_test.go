@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAMQSaturationCrossesTheAlertRate(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 2})
+	defer SetAMQAlertRate(amqAlertRate)
+	SetAMQAlertRate(0.05)
+
+	previous := AMQStats()[0].FalsePositiveRate
+	alertedAt := -1
+	for i := 0; i < 2000 && alertedAt < 0; i++ {
+		updateAMQ(0, fmt.Sprint("filler ", i))
+		stat := AMQStats()[0]
+		if stat.FalsePositiveRate < previous {
+			t.Fatalf("rate fell from %.5f to %.5f after %d inserts", previous, stat.FalsePositiveRate, i+1)
+		}
+		previous = stat.FalsePositiveRate
+		if stat.Alert {
+			alertedAt = i
+		}
+	}
+	if alertedAt < 0 {
+		t.Fatalf("rate only reached %.4f, never the 0.05 alert threshold", previous)
+	}
+	if AMQStats()[1].Alert {
+		t.Fatal("untouched shard is alerting")
+	}
+
+	rec := httptest.NewRecorder()
+	handleMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{`amq_saturated{shard="0"} 1`, `amq_saturated{shard="1"} 0`, "amq_alert_rate 0.05"} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("metrics missing %q:\n%s", line, body)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+)
+
+// Proof for several leaves of one shard at once. Siblings holds each needed node only once, in
+// the order verification consumes them: level by level, left to right. Nodes the verifier can
+// compute from the proven leaves are left out.
+type Multiproof struct {
+	Indices   []int // sorted, distinct leaf positions
+	LeafCount int
+	Siblings  []string
+}
+
+// Multiproof for the blocks at indices; empty if the shard or any index is unknown.
+// Its leaves are the blocks' shardLeaf values.
+func generateMultiproof(shardIndex int, indices []int) Multiproof {
+	for _, i := range indices {
+		if !hasBlock(shardIndex, i) {
+			return Multiproof{}
+		}
+	}
+	return multiproofOfHashes(shardLeaves(shardIndex, merkleForest[shardIndex].Blocks), indices)
+}
+
+func multiproofOfHashes(hashes []string, indices []int) Multiproof {
+	proof := Multiproof{Indices: sortedDistinct(indices), LeafCount: len(hashes)}
+	if len(proof.Indices) == 0 {
+		return Multiproof{}
+	}
+
+	level := hashes
+	known := proof.Indices
+	for len(level) > 1 {
+		have := make(map[int]bool, len(known))
+		for _, i := range known {
+			have[i] = true
+		}
+		var parents []int
+		for _, i := range known {
+			sibling := i ^ 1
+			if sibling < len(level) && !have[sibling] {
+				proof.Siblings = append(proof.Siblings, level[sibling])
+			}
+			if len(parents) == 0 || parents[len(parents)-1] != i/2 {
+				parents = append(parents, i/2)
+			}
+		}
+		level = karyLevel(level, 2)
+		known = parents
+	}
+	return proof
+}
+
+// Checks that leaves, given in the order of proof.Indices, all fold up to root
+func verifyMultiproof(proof Multiproof, leaves []string, root string) bool {
+	if len(proof.Indices) == 0 || len(leaves) != len(proof.Indices) {
+		return false
+	}
+	nodes := make(map[int]string, len(leaves))
+	known := proof.Indices
+	for k, i := range known {
+		if i < 0 || i >= proof.LeafCount || (k > 0 && i <= known[k-1]) {
+			return false
+		}
+		nodes[i] = leaves[k]
+	}
+
+	siblings := proof.Siblings
+	width := proof.LeafCount
+	for width > 1 {
+		next := make(map[int]string)
+		var parents []int
+		for _, i := range known {
+			if _, done := next[i/2]; done {
+				continue
+			}
+			left, right := i&^1, i|1
+			if right >= width {
+				right = left // odd node out is paired with itself
+			}
+			pair := [2]string{}
+			for side, pos := range [2]int{left, right} {
+				if hash, ok := nodes[pos]; ok {
+					pair[side] = hash
+					continue
+				}
+				if len(siblings) == 0 {
+					return false
+				}
+				pair[side], siblings = siblings[0], siblings[1:]
+			}
+			sum := sha256.Sum256([]byte(pair[0] + pair[1]))
+			next[i/2] = hex.EncodeToString(sum[:])
+			parents = append(parents, i/2)
+		}
+		nodes, known = next, parents
+		width = (width + 1) / 2
+	}
+	return len(siblings) == 0 && hashesEqual(nodes[0], root)
+}
+
+func sortedDistinct(indices []int) []int {
+	out := append([]int(nil), indices...)
+	sort.Ints(out)
+	var distinct []int
+	for k, i := range out {
+		if k == 0 || i != out[k-1] {
+			distinct = append(distinct, i)
+		}
+	}
+	return distinct
+}
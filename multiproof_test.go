@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestMultiproofVerifiesAndSharesNodes(t *testing.T) {
+	for _, size := range []int{8, 7} {
+		defer InstallTestForest(Forest{benchShard(0, size)})()
+		shard := merkleForest[0]
+		indices := []int{5, 1, 2, 3, size - 1, 3}
+
+		proof := generateMultiproof(0, indices)
+		var leaves []string
+		for _, i := range proof.Indices {
+			leaves = append(leaves, shardLeaf(0, shard.Blocks[i].Hash))
+		}
+		if !verifyMultiproof(proof, leaves, shard.MerkleRoot) {
+			t.Fatalf("%d leaves: multiproof for %v failed", size, proof.Indices)
+		}
+
+		single := 0
+		for _, i := range proof.Indices {
+			single += len(generateMerkleProof(0, i))
+		}
+		if len(proof.Siblings) >= single {
+			t.Fatalf("%d leaves: multiproof carries %d nodes, single proofs %d", size, len(proof.Siblings), single)
+		}
+
+		swapped := append([]string(nil), leaves...)
+		swapped[0], swapped[1] = swapped[1], swapped[0]
+		if verifyMultiproof(proof, swapped, shard.MerkleRoot) {
+			t.Fatalf("%d leaves: multiproof verified leaves in the wrong positions", size)
+		}
+		padded := proof
+		padded.Siblings = append(append([]string(nil), proof.Siblings...), leaves[0])
+		if verifyMultiproof(padded, leaves, shard.MerkleRoot) {
+			t.Fatalf("%d leaves: multiproof verified with an unused sibling", size)
+		}
+	}
+	if proof := generateMultiproof(0, []int{0, 99}); len(proof.Indices) != 0 {
+		t.Fatal("multiproof built for a missing block")
+	}
+}
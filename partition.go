@@ -0,0 +1,274 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// The two sides of a simulated network partition
+const (
+	PartitionSideA = 0 // keeps the live forest
+	PartitionSideB = 1 // works on a copy until Heal
+)
+
+// One side's view while partitioned: its validators, its copies of the forest and ledger, and a
+// scratch store
+type partitionSide struct {
+	validators map[string]*ValidatorProfile
+	forest     Forest
+	amqFilters []AMQFilter
+	ledger     *State
+	store      Store
+}
+
+// Validators, forest and ledger state set aside by Partition
+type partitionState struct {
+	all    map[string]*ValidatorProfile
+	ledger *State // ledger at the split, which Heal replays the surviving blocks onto
+	sideB  partitionSide
+	mode   int // CAP mode before the partition
+}
+
+var partition *partitionState
+
+var errNotPartitioned = errors.New("network is not partitioned")
+
+// Splits the validator set into two groups that can no longer see each other. Side A keeps the
+// live forest and ledger and votes with groupA only; side B gets copies of both, voted on by
+// groupB, and is reached through OnPartitionSide. The mempool stays shared.
+func Partition(groupA, groupB []string) error {
+	if partition != nil {
+		return fmt.Errorf("already partitioned")
+	}
+	if len(groupA) == 0 || len(groupB) == 0 {
+		return fmt.Errorf("both partition groups need at least one validator")
+	}
+	defer lockForestWrite()()
+	consensusMu.Lock()
+	defer consensusMu.Unlock()
+	sideA, sideB := make(map[string]*ValidatorProfile), make(map[string]*ValidatorProfile)
+	for side, group := range [][]string{groupA, groupB} {
+		for _, id := range group {
+			v, ok := validators[id]
+			if !ok {
+				return fmt.Errorf("unknown validator %s", id)
+			}
+			if sideA[id] != nil || sideB[id] != nil {
+				return fmt.Errorf("validator %s is in both groups", id)
+			}
+			if side == PartitionSideA {
+				sideA[id] = v
+			} else {
+				sideB[id] = v
+			}
+		}
+	}
+
+	partition = &partitionState{
+		all:    validators,
+		ledger: ledger.clone(),
+		sideB: partitionSide{
+			validators: sideB,
+			forest:     cloneForest(merkleForest),
+			amqFilters: cloneAMQFilters(amqFilters),
+			ledger:     ledger.clone(),
+			store:      newMemoryStore(),
+		},
+		mode: currentState,
+	}
+	validators = sideA
+	currentState = PartitionTolerance
+	fmt.Printf("Network partitioned: %v | %v\n", sortedIDs(sideA), sortedIDs(sideB))
+	return nil
+}
+
+// Runs fn with the given side's validators, forest and ledger active
+func OnPartitionSide(side int, fn func()) error {
+	if partition == nil {
+		return errNotPartitioned
+	}
+	switch side {
+	case PartitionSideA:
+		fn()
+		return nil
+	case PartitionSideB:
+	default:
+		return fmt.Errorf("no partition side %d", side)
+	}
+
+	b := &partition.sideB
+	unlock := lockForestWrite()
+	consensusMu.Lock()
+	liveValidators, liveForest, liveAMQ, liveLedger, liveStore := validators, merkleForest, amqFilters, ledger, store
+	validators, merkleForest, amqFilters, ledger, store = b.validators, b.forest, b.amqFilters, b.ledger, b.store
+	consensusMu.Unlock()
+	unlock()
+	defer func() {
+		defer lockForestWrite()()
+		consensusMu.Lock()
+		b.validators, b.forest, b.amqFilters, b.ledger, b.store = validators, merkleForest, amqFilters, ledger, store
+		validators, merkleForest, amqFilters, ledger, store = liveValidators, liveForest, liveAMQ, liveLedger, liveStore
+		consensusMu.Unlock()
+	}()
+	fn()
+	return nil
+}
+
+// Ends the partition and merges side B's forest into the live one. In each shard that diverged,
+// the blocks the shard produced itself on either side compete through deterministicResolution;
+// the losing side's blocks are the conflicting ones and are dropped. Copies that state sync
+// brought in from other shards don't compete: they are appended after the winner as long as
+// their original survived the merge. The ledger is rebuilt from the split by replaying the
+// surviving blocks, so transfers in dropped blocks don't stay applied. Changed shards are
+// persisted, and the full validator set and CAP mode come back.
+func Heal() error {
+	defer lockForestWrite()()
+	if partition == nil {
+		return errNotPartitioned
+	}
+	local, remote := merkleForest, partition.sideB.forest
+	if len(local) != len(remote) {
+		return fmt.Errorf("heal: shard count mismatch: local %d, remote %d", len(local), len(remote))
+	}
+
+	common := make(map[int]int)
+	natives := make(map[int][]Block)
+	copies := make(map[int][]Block)
+	surviving := make(map[string]bool)
+	for i := range local {
+		a, b := local[i].Blocks, remote[i].Blocks
+		n := commonPrefixLen(a, b)
+		for _, block := range a[:n] {
+			surviving[block.Hash] = true
+		}
+		if n == len(a) && n == len(b) {
+			continue
+		}
+		if n == 0 {
+			return fmt.Errorf("heal: shard %d: no common genesis", i)
+		}
+		nativeA, copiesA := splitNative(a[n-1], a[n:])
+		nativeB, copiesB := splitNative(b[n-1], b[n:])
+		winner := nativeA
+		switch {
+		case len(nativeA) == 0:
+			winner = nativeB
+		case len(nativeB) > 0:
+			fmt.Printf("Shard %d diverged after height %d.\n", i, n-1)
+			prefix := a[:n:n]
+			winner = deterministicResolution(append(prefix, nativeA...), append(prefix, nativeB...))[n:]
+		}
+		for _, block := range winner {
+			surviving[block.Hash] = true
+		}
+		common[i], natives[i], copies[i] = n, winner, append(copiesA, copiesB...)
+	}
+
+	healed := cloneForest(local)
+	for i, n := range common {
+		suffix := natives[i]
+		seen := make(map[string]bool)
+		for _, block := range append(local[i].Blocks[:n:n], suffix...) {
+			seen[block.Hash] = true
+		}
+		for _, block := range copies[i] {
+			if surviving[block.Hash] && !seen[block.Hash] {
+				suffix = append(suffix, block)
+				seen[block.Hash] = true
+			}
+		}
+		shard, err := extendShard(i, local[i].Blocks[:n], suffix)
+		if err != nil {
+			return fmt.Errorf("heal: shard %d: %w", i, err)
+		}
+		shard.Isolation, shard.State = local[i].Isolation, local[i].State
+		healed[i] = shard
+	}
+
+	merkleForest = healed
+	ledger = replayLedger(partition.ledger, healed, common)
+	consensusMu.Lock()
+	validators = partition.all
+	consensusMu.Unlock()
+	currentState = partition.mode
+	partition = nil
+	rebuildAMQFilters()
+	for i, from := range common {
+		if err := persistShard(i, from); err != nil {
+			fmt.Println("Storage error:", err)
+		}
+	}
+	fmt.Printf("Partition healed: %d shards merged.\n", len(common))
+	return nil
+}
+
+// Ledger for the healed forest: the state at the split with every block past each diverged
+// shard's common prefix applied on top, lowest height first and shards in index order within a
+// height. Copies are skipped as already applied. A surviving block whose transfers no longer
+// apply, because the other side spent the same funds first, stays in the forest but is reported
+// and left out of the ledger.
+func replayLedger(base *State, healed Forest, common map[int]int) *State {
+	type placed struct {
+		shard int
+		block Block
+	}
+	var blocks []placed
+	for i := range healed {
+		if n, ok := common[i]; ok {
+			for _, block := range healed[i].Blocks[n:] {
+				blocks = append(blocks, placed{i, block})
+			}
+		}
+	}
+	sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].block.Index < blocks[j].block.Index })
+
+	state := base.clone()
+	for _, p := range blocks {
+		if err := state.applyBlock(p.block); err != nil {
+			fmt.Printf("Heal: shard %d block %d left out of the ledger: %v\n", p.shard, p.block.Index, err)
+		}
+	}
+	return state
+}
+
+// Splits blocks added after prev into those the shard produced itself, which link to the block
+// before them, and the copies state sync appended from other shards, which don't
+func splitNative(prev Block, blocks []Block) (native, copies []Block) {
+	for _, block := range blocks {
+		if block.PrevHash == prev.Hash {
+			native = append(native, block)
+		} else {
+			copies = append(copies, block)
+		}
+		prev = block
+	}
+	return native, copies
+}
+
+// Copy of a forest whose block slices can grow independently of the original
+func cloneForest(forest []Shard) Forest {
+	clone := make(Forest, len(forest))
+	for i, shard := range forest {
+		clone[i] = shard
+		clone[i].Blocks = append([]Block(nil), shard.Blocks...)
+	}
+	return clone
+}
+
+func cloneAMQFilters(filters []AMQFilter) []AMQFilter {
+	clone := make([]AMQFilter, len(filters))
+	for i, f := range filters {
+		clone[i] = AMQFilter{bits: append([]uint64(nil), f.bits...)}
+	}
+	return clone
+}
+
+func sortedIDs(set map[string]*ValidatorProfile) []string {
+	var ids []string
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,99 @@
+package main
+
+import "testing"
+
+func TestHealReplaysLedgerFromSurvivingBlocks(t *testing.T) {
+	defer UseTestDifficulty()()
+	defer InstallTestForest(NewTestForest(1, 1))()
+	previousLedger := ledger
+	defer func() { ledger = previousLedger }()
+	ledger = newState()
+	ledger.Balances["alice"] = 100
+
+	if err := Partition([]string{"Validator1"}, []string{"Validator2"}); err != nil {
+		t.Fatal(err)
+	}
+	genesis := merkleForest[0].Blocks[0]
+	toBob := MineTestBlockWith(genesis, Transaction{From: "alice", To: "bob", Amount: 30})
+	if err := ImportBlock(0, toBob); err != nil {
+		t.Fatal(err)
+	}
+	toCarol := MineTestBlockWith(genesis, Transaction{From: "alice", To: "carol", Amount: 40})
+	err := OnPartitionSide(PartitionSideB, func() {
+		if err := ImportBlock(0, toCarol); err != nil {
+			t.Fatal(err)
+		}
+		if got := ledger.Balances["carol"]; got != 40 {
+			t.Errorf("side B ledger: carol has %d, want 40", got)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ledger.Balances["carol"]; got != 0 {
+		t.Fatalf("side A ledger saw side B's transfer: carol has %d", got)
+	}
+
+	if err := Heal(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]uint64{"alice": 70, "bob": 30, "carol": 0}
+	if merkleForest[0].Blocks[1].Hash == toCarol.Hash {
+		want = map[string]uint64{"alice": 60, "bob": 0, "carol": 40}
+	}
+	for account, balance := range want {
+		if got := ledger.Balances[account]; got != balance {
+			t.Errorf("after heal %s has %d, want %d", account, got, balance)
+		}
+	}
+}
+
+func TestHealKeepsNonConflictingBlocksFromBothSides(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 3})
+	validators = map[string]*ValidatorProfile{
+		"Validator1": testValidator(0.9, "US"),
+		"Validator2": testValidator(0.9, "EU"),
+		"Validator3": testValidator(0.9, "AS"),
+		"Validator4": testValidator(0.9, "AF"),
+	}
+	if err := Partition([]string{"Validator1", "Validator2"}, []string{"Validator3", "Validator4"}); err != nil {
+		t.Fatal(err)
+	}
+	add := func(shard int, data, validator string) {
+		t.Helper()
+		if err := addBlockToShard(shard, data, validator); err != nil {
+			t.Fatalf("%s: %v", data, err)
+		}
+	}
+	add(0, "side A only", "Validator1")
+	add(2, "side A contested", "Validator1")
+	err := OnPartitionSide(PartitionSideB, func() {
+		add(1, "side B only", "Validator3")
+		add(2, "side B contested", "Validator3")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Heal(); err != nil {
+		t.Fatal(err)
+	}
+	MustValidate(t, merkleForest)
+	holds := func(shard int, data string) bool {
+		for _, block := range merkleForest[shard].Blocks {
+			if block.Data == data {
+				return true
+			}
+		}
+		return false
+	}
+	if !holds(0, "side A only") || !holds(1, "side B only") {
+		t.Fatal("a block produced on only one side was lost in the heal")
+	}
+	if holds(2, "side A contested") == holds(2, "side B contested") {
+		t.Fatal("want exactly one side's block at the contested height of shard 2")
+	}
+	if len(validators) != 4 || partition != nil {
+		t.Fatal("heal did not restore the full validator set")
+	}
+}
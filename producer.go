@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Producer paces block production: each interval it packs the mempool into at most one block
+// per shard, with proposers taken in priority order round-robin
+type Producer struct {
+	interval time.Duration
+	done     chan struct{}
+
+	mu       sync.Mutex
+	produced int
+	noQuorum int // consecutive attempts that ended in errNoQuorum
+}
+
+// Consecutive no-quorum failures after which the producer warns that it has stalled
+const noQuorumAlertAfter = 3
+
+// Starts producing every interval until ctx is done
+func StartProducer(ctx context.Context, interval time.Duration) *Producer {
+	p := &Producer{interval: interval, done: make(chan struct{})}
+	go p.run(ctx)
+	return p
+}
+
+// Blocks committed so far
+func (p *Producer) Produced() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.produced
+}
+
+// Attempts in a row that failed for lack of quorum; reset by the next committed block
+func (p *Producer) NoQuorumStreak() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.noQuorum
+}
+
+// Waits for the producer to stop after its context is done
+func (p *Producer) Wait() {
+	<-p.done
+}
+
+func (p *Producer) run(ctx context.Context) {
+	defer close(p.done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for tick := 0; ; tick++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.produceRound(ctx, tick)
+		}
+	}
+}
+
+// One block per shard, each given at most one interval to finish
+func (p *Producer) produceRound(ctx context.Context, tick int) {
+	chainMu.RLock()
+	defer chainMu.RUnlock()
+	defer lockForestWrite()()
+
+	consensusMu.RLock()
+	proposers := validatorsByPriority()
+	consensusMu.RUnlock()
+	if len(proposers) == 0 {
+		return
+	}
+	roundCtx, cancel := context.WithTimeout(ctx, p.interval)
+	defer cancel()
+	for shard := range merkleForest {
+		proposer := proposers[(tick+shard)%len(proposers)]
+		data := fmt.Sprintf("Produced block %d", tick)
+		_, err := produceBlock(roundCtx, shard, data, proposer)
+		p.mu.Lock()
+		switch {
+		case err == nil:
+			p.produced++
+			p.noQuorum = 0
+		case errors.Is(err, errNoQuorum):
+			p.noQuorum++
+		}
+		streak := p.noQuorum
+		p.mu.Unlock()
+		if err != nil {
+			fmt.Printf("Producer: shard %d: %v\n", shard, err)
+		}
+		if streak >= noQuorumAlertAfter && errors.Is(err, errNoQuorum) {
+			fmt.Printf("Producer: stalled, no quorum for %d attempts in a row; check validator pings and trust\n", streak)
+		}
+	}
+}
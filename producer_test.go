@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestProducerPacesBlocks(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1})
+	SetMaxShardCapacity(100)
+	const interval = 50 * time.Millisecond
+	const window = 5*interval + interval/2
+
+	ctx, cancel := context.WithTimeout(context.Background(), window)
+	defer cancel()
+	p := StartProducer(ctx, interval)
+	p.Wait()
+
+	want := int(window / interval)
+	if got := p.Produced(); got < want-1 || got > want+1 {
+		t.Fatalf("produced %d blocks in %v at a %v interval, want %d ± 1", got, window, interval, want)
+	}
+	if height := len(merkleForest[0].Blocks) - 1; height != p.Produced() {
+		t.Fatalf("shard holds %d produced blocks, producer reported %d", height, p.Produced())
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MerkleProof is a self-contained inclusion proof for one leaf of a binary Merkle tree
+type MerkleProof struct {
+	Leaf     string
+	Index    int
+	Siblings []string
+	Root     string
+}
+
+// Interchange form: each sibling says which side of the running hash it sits on
+type merkleProofJSON struct {
+	Leaf     string              `json:"leaf"`
+	Index    int                 `json:"index"`
+	Siblings []merkleSiblingJSON `json:"siblings"`
+	Root     string              `json:"root"`
+}
+
+type merkleSiblingJSON struct {
+	Hash     string `json:"hash"`
+	Position string `json:"position"` // "left" or "right"
+}
+
+// Proof for a block against its shard's current root
+func shardMerkleProof(shardIndex, blockIndex int) MerkleProof {
+	shard := merkleForest[shardIndex]
+	return MerkleProof{
+		Leaf:     shardLeaf(shardIndex, shard.Blocks[blockIndex].Hash),
+		Index:    blockIndex,
+		Siblings: generateMerkleProof(shardIndex, blockIndex),
+		Root:     shard.MerkleRoot,
+	}
+}
+
+func (p MerkleProof) Verify() bool {
+	return verifyMerkleProofOfHashes(p.Leaf, p.Index, p.Siblings, p.Root)
+}
+
+// Side of the sibling at each level, following the same index arithmetic as verification
+func siblingPosition(index, level int) string {
+	if (index>>level)%2 == 0 {
+		return "right"
+	}
+	return "left"
+}
+
+func (p MerkleProof) MarshalJSON() ([]byte, error) {
+	out := merkleProofJSON{Leaf: p.Leaf, Index: p.Index, Root: p.Root, Siblings: []merkleSiblingJSON{}}
+	for level, hash := range p.Siblings {
+		out.Siblings = append(out.Siblings, merkleSiblingJSON{Hash: hash, Position: siblingPosition(p.Index, level)})
+	}
+	return json.Marshal(out)
+}
+
+// Rejects sibling positions that contradict the leaf index
+func (p *MerkleProof) UnmarshalJSON(data []byte) error {
+	var in merkleProofJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	siblings := make([]string, 0, len(in.Siblings))
+	for level, s := range in.Siblings {
+		if want := siblingPosition(in.Index, level); s.Position != want {
+			return fmt.Errorf("sibling %d: position %q, index %d implies %q", level, s.Position, in.Index, want)
+		}
+		siblings = append(siblings, s.Hash)
+	}
+	*p = MerkleProof{Leaf: in.Leaf, Index: in.Index, Siblings: siblings, Root: in.Root}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMerkleProofJSONRoundTrip(t *testing.T) {
+	defer UseTestDifficulty()()
+	defer InstallTestForest(NewTestForest(1, 5))()
+
+	for i := 0; i < 5; i++ {
+		proof := shardMerkleProof(0, i)
+		if !proof.Verify() {
+			t.Fatalf("proof for block %d does not verify", i)
+		}
+		data, err := json.Marshal(proof)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded MerkleProof
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, proof) || !decoded.Verify() {
+			t.Fatalf("block %d: decoded %+v, want %+v verifying", i, decoded, proof)
+		}
+	}
+}
+
+func TestMerkleProofJSONSchema(t *testing.T) {
+	defer UseTestDifficulty()()
+	defer InstallTestForest(NewTestForest(1, 4))()
+	data, err := json.Marshal(shardMerkleProof(0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for k := range doc {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"index", "leaf", "root", "siblings"}) {
+		t.Fatalf("top-level keys %v", keys)
+	}
+	var siblings []map[string]string
+	if err := json.Unmarshal(doc["siblings"], &siblings); err != nil {
+		t.Fatal(err)
+	}
+	// Index 2 is a left child at the bottom level and a right child above it
+	if len(siblings) != 2 || siblings[0]["position"] != "right" || siblings[1]["position"] != "left" || siblings[0]["hash"] == "" {
+		t.Fatalf("siblings %v, want {hash, position} entries right then left", siblings)
+	}
+}
+
+func TestMerkleProofJSONRejectsWrongPosition(t *testing.T) {
+	data := []byte(`{"leaf":"aa","index":0,"siblings":[{"hash":"bb","position":"left"}],"root":"cc"}`)
+	var p MerkleProof
+	if err := json.Unmarshal(data, &p); err == nil {
+		t.Fatal("sibling on the wrong side accepted")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+const defaultProofCacheSize = 1024 // proofs kept before the least recently used is evicted
+
+// Counters reported by ProofCacheStats
+type ProofCacheStat struct {
+	Hits, Misses, Invalidations uint64
+	Size                        int
+}
+
+type proofKey struct {
+	shard, index int
+	root         string
+}
+
+type proofEntry struct {
+	key   proofKey
+	proof []string
+}
+
+// LRU of Merkle proofs keyed by shard root and block index. A proof is fixed by the root it was
+// built against, so entries never go stale; once a shard's root moves on, the old ones are dropped.
+type proofCache struct {
+	mu      sync.Mutex // proofs are served under forestMu.RLock, so lookups can run concurrently
+	size    int
+	order   *list.List // most recently used first
+	entries map[proofKey]*list.Element
+	roots   map[int]string // root each shard's cached proofs were built against
+	stat    ProofCacheStat
+}
+
+var merkleProofCache = newProofCache(defaultProofCacheSize)
+
+func newProofCache(size int) *proofCache {
+	return &proofCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[proofKey]*list.Element),
+		roots:   make(map[int]string),
+	}
+}
+
+// Cached proof for the block at index, or build it and keep it
+func (c *proofCache) get(shard, index int, root string, build func() []string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.roots[shard]; ok && cached != root {
+		c.invalidate(shard)
+	}
+	key := proofKey{shard, index, root}
+	if e, ok := c.entries[key]; ok {
+		c.stat.Hits++
+		c.order.MoveToFront(e)
+		return append([]string(nil), e.Value.(*proofEntry).proof...)
+	}
+
+	c.stat.Misses++
+	proof := build()
+	c.roots[shard] = root
+	c.entries[key] = c.order.PushFront(&proofEntry{key, append([]string(nil), proof...)})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		delete(c.entries, oldest.Value.(*proofEntry).key)
+		c.order.Remove(oldest)
+	}
+	return proof
+}
+
+// Drops every proof cached for shard
+func (c *proofCache) invalidate(shard int) {
+	for e := c.order.Front(); e != nil; {
+		next := e.Next()
+		if key := e.Value.(*proofEntry).key; key.shard == shard {
+			delete(c.entries, key)
+			c.order.Remove(e)
+		}
+		e = next
+	}
+	delete(c.roots, shard)
+	c.stat.Invalidations++
+}
+
+// Hit, miss and invalidation counts of the Merkle proof cache, with its current size
+func ProofCacheStats() ProofCacheStat {
+	c := merkleProofCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stat := c.stat
+	stat.Size = c.order.Len()
+	return stat
+}
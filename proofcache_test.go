@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestProofCacheHitsUntilTheRootChanges(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 2})
+	previous := merkleProofCache
+	defer func() { merkleProofCache = previous }()
+	merkleProofCache = newProofCache(defaultProofCacheSize)
+
+	first := generateMerkleProof(0, 0)
+	again := generateMerkleProof(0, 0)
+	generateMerkleProof(1, 0)
+	if s := ProofCacheStats(); s.Hits != 1 || s.Misses != 2 || s.Size != 2 {
+		t.Fatalf("after a repeat request: %+v, want 1 hit, 2 misses, 2 cached", s)
+	}
+	if len(first) != len(again) {
+		t.Fatal("cached proof differs from the built one")
+	}
+
+	if err := ImportBlock(0, MineTestBlockAfter(merkleForest[0].Blocks[0], "moves the root")); err != nil {
+		t.Fatal(err)
+	}
+	if !validateMerkleProof(0, 0, generateMerkleProof(0, 0)) {
+		t.Fatal("proof after the root changed does not verify")
+	}
+	generateMerkleProof(1, 0)
+	if s := ProofCacheStats(); s.Invalidations != 1 || s.Misses != 3 || s.Hits != 2 {
+		t.Fatalf("after a block on shard 0: %+v, want shard 0 invalidated and shard 1 still cached", s)
+	}
+}
+
+func TestProofCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newProofCache(2)
+	build := func(p string) func() []string { return func() []string { return []string{p} } }
+	c.get(0, 0, "r", build("a"))
+	c.get(0, 1, "r", build("b"))
+	c.get(0, 0, "r", build("a")) // a is now the most recent
+	c.get(0, 2, "r", build("c")) // evicts b
+
+	if got := c.get(0, 1, "r", build("b2")); got[0] != "b2" {
+		t.Fatal("least recently used proof was kept")
+	}
+	if got := c.get(0, 2, "r", build("c2")); got[0] != "c" {
+		t.Fatal("recently used proof was evicted")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var errProofServiceClosed = errors.New("proof service closed")
+
+// ProofService generates shard Merkle proofs on a fixed number of workers fed by a bounded queue,
+// so a burst of requests queues up instead of spawning a goroutine each
+type ProofService struct {
+	jobs      chan proofJob
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	mu        sync.RWMutex // held for reading while submitting, for writing while closing
+	closed    bool
+	generate  func(shardIndex, blockIndex int) (MerkleProof, error)
+}
+
+type proofJob struct {
+	shard, block int
+	result       chan proofResult
+}
+
+type proofResult struct {
+	proof MerkleProof
+	err   error
+}
+
+// Starts workers goroutines sharing a queue of queueSize pending requests
+func NewProofService(workers, queueSize int) *ProofService {
+	if workers < 1 {
+		workers = 1
+	}
+	s := &ProofService{jobs: make(chan proofJob, max(queueSize, 0)), generate: generateProof}
+	for i := 0; i < workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+	return s
+}
+
+// Proof for a block, waiting for queue space and a free worker
+func (s *ProofService) Request(shardIndex, blockIndex int) (MerkleProof, error) {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return MerkleProof{}, errProofServiceClosed
+	}
+	job := proofJob{shard: shardIndex, block: blockIndex, result: make(chan proofResult, 1)}
+	s.jobs <- job
+	s.mu.RUnlock()
+
+	r := <-job.result
+	return r.proof, r.err
+}
+
+// Stops accepting requests and waits for queued ones to finish
+func (s *ProofService) Close() {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		close(s.jobs)
+		s.mu.Unlock()
+		s.wg.Wait()
+	})
+}
+
+func (s *ProofService) work() {
+	defer s.wg.Done()
+	for job := range s.jobs {
+		proof, err := s.generate(job.shard, job.block)
+		job.result <- proofResult{proof, err}
+	}
+}
+
+// Bounds-checked shardMerkleProof under the forest read lock
+func generateProof(shardIndex, blockIndex int) (MerkleProof, error) {
+	forestMu.RLock()
+	defer forestMu.RUnlock()
+	if shardIndex < 0 || shardIndex >= len(merkleForest) {
+		return MerkleProof{}, fmt.Errorf("shard %d out of range", shardIndex)
+	}
+	if blockIndex < 0 || blockIndex >= len(merkleForest[shardIndex].Blocks) {
+		return MerkleProof{}, fmt.Errorf("block %d out of range in shard %d", blockIndex, shardIndex)
+	}
+	return shardMerkleProof(shardIndex, blockIndex), nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProofServiceBoundsInFlightRequests(t *testing.T) {
+	defer UseTestDifficulty()()
+	defer InstallTestForest(NewTestForest(2, 8))()
+	const workers, requests = 4, 1000
+
+	s := NewProofService(workers, 16)
+	defer s.Close()
+	var inFlight, peak atomic.Int32
+	s.generate = func(shardIndex, blockIndex int) (MerkleProof, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(10 * time.Microsecond) // let requests pile up behind the workers
+		return generateProof(shardIndex, blockIndex)
+	}
+
+	var wg sync.WaitGroup
+	var failed atomic.Int32
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(shard, block int) {
+			defer wg.Done()
+			proof, err := s.Request(shard, block)
+			if err != nil || !proof.Verify() || proof.Index != block {
+				failed.Add(1)
+			}
+		}(i%2, i%8)
+	}
+	wg.Wait()
+
+	if n := failed.Load(); n != 0 {
+		t.Fatalf("%d of %d requests failed or returned a bad proof", n, requests)
+	}
+	if p := peak.Load(); p > workers || p < 1 {
+		t.Fatalf("%d proofs in flight at once, want at most %d", p, workers)
+	}
+}
+
+func TestProofServiceRejectsAfterClose(t *testing.T) {
+	s := NewProofService(1, 0)
+	s.Close()
+	if _, err := s.Request(0, 0); err != errProofServiceClosed {
+		t.Fatalf("request after Close: %v, want errProofServiceClosed", err)
+	}
+}
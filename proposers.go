@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// Audit record of who proposed a block and why. View 0 belongs to the requested proposer;
+// every later view goes to the next validator in the rotation, ranked by proposerScore.
+// RunnerUp is the validator the rotation would have turned to next.
+type ProposerEntry struct {
+	ShardIndex    int
+	Height        int
+	Proposer      string
+	ProposerScore float64
+	ProposerStake int
+	View          int    // consensus view the proposal succeeded in (0 = first proposer)
+	Selection     string // why the proposer held the view
+	Rotation      []string
+	RunnerUp      string
+	RunnerUpScore float64
+}
+
+// ProposerLog keeps one entry per accepted block, keyed by shard and height
+type ProposerLog struct {
+	entries map[[2]int]ProposerEntry
+}
+
+var proposerLog = &ProposerLog{entries: make(map[[2]int]ProposerEntry)}
+
+// Stake-weighted VRF score of a validator for the slot after prevHash
+func proposerScore(id, prevHash string) float64 {
+	v, ok := validators[id]
+	if !ok {
+		return 0
+	}
+	vrf := sha256.Sum256([]byte(fmt.Sprintf("%s:%s", id, prevHash)))
+	return float64(vrf[0]) / 255.0 * float64(v.StakeLevel) / 3.0
+}
+
+// Records the accepted block's proposer from the same rotation runConsensusRounds followed
+// when first was asked to propose
+func (l *ProposerLog) record(shardIndex int, block Block, view int, first string) {
+	consensusMu.RLock()
+	defer consensusMu.RUnlock()
+	rotation := rankProposers(first, block.PrevHash)
+	entry := ProposerEntry{
+		ShardIndex:    shardIndex,
+		Height:        block.Index,
+		Proposer:      block.Validator,
+		ProposerScore: proposerScore(block.Validator, block.PrevHash),
+		View:          view,
+		Selection:     "requested proposer",
+		Rotation:      rotation,
+	}
+	if view > 0 {
+		entry.Selection = fmt.Sprintf("view change: rotation position %d by VRF score", view%len(rotation))
+	}
+	if v, ok := validators[block.Validator]; ok {
+		entry.ProposerStake = v.StakeLevel
+	}
+	if len(rotation) > 1 {
+		entry.RunnerUp = rotation[(view+1)%len(rotation)]
+		entry.RunnerUpScore = proposerScore(entry.RunnerUp, block.PrevHash)
+	}
+	l.entries[[2]int{shardIndex, block.Index}] = entry
+}
+
+// Proposer record for a shard height; heights repeat across shards, so both are needed
+func ProposerAt(shardIndex, height int) (ProposerEntry, bool) {
+	entry, ok := proposerLog.entries[[2]int{shardIndex, height}]
+	return entry, ok
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProposerLogMatchesBlockValidator(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1})
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"A": testValidator(0.9, "US"),
+		"B": testValidator(0.9, "EU"),
+		"C": testValidator(0.9, "AS"),
+	})()
+	defer useConsensusStubs(fixedVote(true), &countingProofProvider{})()
+	defer useTestProposer(&stallingProposer{stalled: map[string]bool{"A": true}})()
+
+	for _, requested := range []string{"B", "A"} {
+		receipt, err := AddBlockCtx(context.Background(), "logged", requested)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block := merkleForest[receipt.Shard].Blocks[len(merkleForest[receipt.Shard].Blocks)-1]
+		entry, ok := ProposerAt(receipt.Shard, block.Index)
+		if !ok {
+			t.Fatalf("no log entry for height %d", block.Index)
+		}
+		if entry.Proposer != block.Validator || entry.View != receipt.View {
+			t.Fatalf("log says %s in view %d, block was proposed by %s in view %d", entry.Proposer, entry.View, block.Validator, receipt.View)
+		}
+		if entry.ProposerStake != 3 || entry.ProposerScore <= 0 || entry.RunnerUp == "" || entry.RunnerUp == entry.Proposer {
+			t.Fatalf("entry %+v lacks the proposer's stake, score or a distinct runner-up", entry)
+		}
+	}
+	if entry, _ := ProposerAt(0, 2); entry.View == 0 || entry.Proposer == "A" {
+		t.Fatalf("stalled A's slot logged as %+v, want a view change to another proposer", entry)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var errRateLimited = errors.New("rate limit exceeded")
+
+// Sustained submissions per second plus the burst allowed on top
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// Token bucket: holds up to Burst tokens, refilled continuously at Rate per second
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) take(now time.Time) bool {
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	if b.tokens > float64(b.limit.Burst) {
+		b.tokens = float64(b.limit.Burst)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Per-validator block submission limiter
+type RateLimiter struct {
+	mu        sync.Mutex
+	defaults  RateLimit
+	overrides map[string]RateLimit
+	buckets   map[string]*tokenBucket
+	now       func() time.Time
+}
+
+func newRateLimiter(defaults RateLimit) *RateLimiter {
+	return &RateLimiter{
+		defaults:  defaults,
+		overrides: make(map[string]RateLimit),
+		buckets:   make(map[string]*tokenBucket),
+		now:       time.Now,
+	}
+}
+
+var submissionLimiter = newRateLimiter(RateLimit{Rate: 1, Burst: 5})
+
+// Sets a validator-specific limit, starting it with a full bucket
+func (l *RateLimiter) SetLimit(validator string, limit RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.overrides[validator] = limit
+	delete(l.buckets, validator)
+}
+
+// Takes one token from the validator's bucket, or reports the limit was hit
+func (l *RateLimiter) Allow(validator string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket, ok := l.buckets[validator]
+	if !ok {
+		limit, ok := l.overrides[validator]
+		if !ok {
+			limit = l.defaults
+		}
+		bucket = &tokenBucket{limit: limit, tokens: float64(limit.Burst), last: l.now()}
+		l.buckets[validator] = bucket
+	}
+	if !bucket.take(l.now()) {
+		return fmt.Errorf("%s: %w", validator, errRateLimited)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterRejectsExcessAndRefills(t *testing.T) {
+	clock := time.Unix(1000, 0)
+	l := newRateLimiter(RateLimit{Rate: 2, Burst: 3})
+	l.now = func() time.Time { return clock }
+
+	for i := 0; i < 3; i++ {
+		if err := l.Allow("V1"); err != nil {
+			t.Fatalf("submission %d within the burst: %v", i, err)
+		}
+	}
+	if err := l.Allow("V1"); !errors.Is(err, errRateLimited) {
+		t.Fatalf("fourth submission: %v, want errRateLimited", err)
+	}
+	if err := l.Allow("V2"); err != nil {
+		t.Fatalf("another validator was limited too: %v", err)
+	}
+
+	clock = clock.Add(500 * time.Millisecond) // one token at 2 per second
+	if err := l.Allow("V1"); err != nil {
+		t.Fatalf("after refilling one token: %v", err)
+	}
+	if err := l.Allow("V1"); !errors.Is(err, errRateLimited) {
+		t.Fatalf("second submission after one refill: %v, want errRateLimited", err)
+	}
+}
+
+func TestRateLimiterPerValidatorOverride(t *testing.T) {
+	clock := time.Unix(1000, 0)
+	l := newRateLimiter(RateLimit{Rate: 1, Burst: 1})
+	l.now = func() time.Time { return clock }
+	l.SetLimit("busy", RateLimit{Rate: 1, Burst: 4})
+
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if l.Allow("busy") == nil {
+			allowed++
+		}
+	}
+	if allowed != 4 {
+		t.Fatalf("override allowed %d submissions at once, want its burst of 4", allowed)
+	}
+}
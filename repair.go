@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// One shard cut back by LoadForestWithRepair
+type ShardTruncation struct {
+	Shard  int
+	From   int    // blocks in the backup
+	To     int    // blocks kept
+	Reason string // why the first dropped block failed
+}
+
+// What LoadForestWithRepair had to drop; empty when the backup was intact
+type RepairReport struct {
+	Truncations []ShardTruncation
+}
+
+func (r RepairReport) Repaired() bool { return len(r.Truncations) > 0 }
+
+// Loads a forest backup written by SaveForest and makes it the active forest. Instead of
+// refusing a damaged backup, each shard is cut back to its last valid block: blocks from the
+// first one with a bad hash or PoW, or whose parent is nowhere in the forest, are dropped and the
+// Merkle root is recomputed. A shard whose genesis is damaged can't be repaired and fails the load.
+func LoadForestWithRepair(path string) (RepairReport, error) {
+	var report RepairReport
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return report, err
+	}
+	var file forestFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return report, fmt.Errorf("load forest %s: %w", path, err)
+	}
+	if file.ChainID != chainID {
+		return report, fmt.Errorf("load forest %s: chain %q, want %q", path, file.ChainID, chainID)
+	}
+
+	kept := make([]int, len(file.Shards))
+	reasons := make([]string, len(file.Shards))
+	for i, shard := range file.Shards {
+		kept[i] = len(shard.Blocks)
+		for pos, block := range shard.Blocks {
+			if err := checkStoredBlock(pos, block, shard); err != nil {
+				kept[i], reasons[i] = pos, err.Error()
+				break
+			}
+		}
+	}
+	// Cutting one shard can orphan blocks synced from it into others, so repeat until stable
+	for changed := true; changed; {
+		changed = false
+		known := make(map[string]bool)
+		for i, shard := range file.Shards {
+			for _, block := range shard.Blocks[:kept[i]] {
+				known[block.Hash] = true
+			}
+		}
+		for i, shard := range file.Shards {
+			for pos := 1; pos < kept[i]; pos++ {
+				if block := shard.Blocks[pos]; !known[block.PrevHash] {
+					kept[i], reasons[i] = pos, fmt.Sprintf("block %d: parent %.12s not in forest", block.Index, block.PrevHash)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	forest := make(Forest, len(file.Shards))
+	for i, shard := range file.Shards {
+		if kept[i] == 0 {
+			return RepairReport{}, fmt.Errorf("load forest %s: shard %d genesis: %s", path, i, reasons[i])
+		}
+		forest[i], err = extendShard(i, shard.Blocks[:1], shard.Blocks[1:kept[i]])
+		if err != nil {
+			return RepairReport{}, fmt.Errorf("load forest %s: shard %d: %w", path, i, err)
+		}
+		forest[i].Isolation, forest[i].State = shard.Isolation, shard.State
+		if kept[i] < len(shard.Blocks) {
+			report.Truncations = append(report.Truncations, ShardTruncation{
+				Shard: i, From: len(shard.Blocks), To: kept[i], Reason: reasons[i],
+			})
+		}
+	}
+
+	defer lockForestWrite()()
+	merkleForest = forest
+	shardCount = len(forest)
+	rebuildAMQFilters()
+	for _, t := range report.Truncations {
+		fmt.Printf("Repaired shard %d: kept %d of %d blocks (%s)\n", t.Shard, t.To, t.From, t.Reason)
+		if err := persistShard(t.Shard, t.To); err != nil {
+			fmt.Println("Storage error:", err)
+		}
+	}
+	return report, nil
+}
+
+// Checks a block read back from a backup on its own: a genesis first, then a sealed hash and PoW
+func checkStoredBlock(pos int, block Block, shard Shard) error {
+	if pos == 0 && block.Index != 0 {
+		return fmt.Errorf("first block has index %d, want genesis", block.Index)
+	}
+	if err := validateBlockHash(block, shard); err != nil {
+		return err
+	}
+	return validateBlockPoW(block, shard)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Saves the active forest, lets damage edit the file, and writes it back
+func savedForest(t *testing.T, damage func(*forestFile)) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "forest.json")
+	if err := SaveForest(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file forestFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	damage(&file)
+	if data, err = json.Marshal(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadWithRepairTruncatesACorruptedTail(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 2})
+	InstallTestForest(NewTestForest(2, 4))
+
+	intact := savedForest(t, func(*forestFile) {})
+	if report, err := LoadForestWithRepair(intact); err != nil || report.Repaired() {
+		t.Fatalf("intact backup: %+v %v", report, err)
+	}
+
+	corrupted := savedForest(t, func(f *forestFile) { f.Shards[1].Blocks[2].Data = "tampered" })
+	report, err := LoadForestWithRepair(corrupted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Truncations) != 1 {
+		t.Fatalf("report %+v, want one truncation", report)
+	}
+	if cut := report.Truncations[0]; cut.Shard != 1 || cut.From != 4 || cut.To != 2 || cut.Reason == "" {
+		t.Fatalf("truncation %+v, want shard 1 cut from 4 blocks to 2 with a reason", cut)
+	}
+	if len(merkleForest[0].Blocks) != 4 || len(merkleForest[1].Blocks) != 2 {
+		t.Fatalf("loaded shards hold %d and %d blocks", len(merkleForest[0].Blocks), len(merkleForest[1].Blocks))
+	}
+	MustValidate(t, merkleForest)
+
+	badGenesis := savedForest(t, func(f *forestFile) { f.Shards[0].Blocks[0].Data = "tampered" })
+	if _, err := LoadForestWithRepair(badGenesis); err == nil {
+		t.Fatal("backup with a damaged genesis loaded")
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// What this node does in the network
+type Role int
+
+const (
+	RoleValidator Role = iota // proposes and votes on blocks
+	RoleReplica               // imports and verifies blocks, serves proofs and queries, never proposes
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleValidator:
+		return "Validator"
+	case RoleReplica:
+		return "Replica"
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+var nodeRole = RoleValidator
+
+var errReplica = errors.New("node is a read replica: blocks arrive through ImportBlock only")
+
+// Switches the node's role. A replica refuses local submissions, including those from its own
+// producer, but still accepts blocks through ImportBlock, which verifies them as usual.
+func SetRole(r Role) {
+	nodeRole = r
+	fmt.Printf("Node role: %s\n", r)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReplicaImportsButDoesNotPropose(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1})
+	previous := nodeRole
+	defer func() { nodeRole = previous }()
+	SetRole(RoleReplica)
+
+	if err := addBlockToShards("local", "Validator1"); !errors.Is(err, errReplica) {
+		t.Fatalf("local submission on a replica: %v, want errReplica", err)
+	}
+	if len(merkleForest[0].Blocks) != 1 {
+		t.Fatal("replica added a block of its own")
+	}
+
+	external := MineTestBlockAfter(merkleForest[0].Blocks[0], "from a validator")
+	if err := ImportBlock(0, external); err != nil {
+		t.Fatalf("replica import: %v", err)
+	}
+	if !validateMerkleProof(0, 1, generateMerkleProof(0, 1)) {
+		t.Fatal("replica cannot prove the imported block")
+	}
+	if err := ImportBlock(0, MineTestBlockAfter(merkleForest[0].Blocks[0], "fork")); err == nil {
+		t.Fatal("replica imported a block that does not extend its tip")
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Round model: each view has one proposer; an unresponsive proposer or a rejected proposal triggers a view change
+type ConsensusConfig struct {
+	RoundTimeout      time.Duration // how long to wait for a proposal and its votes
+	MaxRounds         int           // views attempted before giving up on the block
+	MaxVotersPerRound int           // eligible validators polled per round, by trust then stake (0 = all)
+	MinRegions        int           // distinct approver Locations a block needs (0 = no requirement)
+	StaleTrustFactor  float64       // in Availability mode, stale-ping validators vote at this share of their trust (0 = excluded)
+}
+
+var consensusConfig = ConsensusConfig{RoundTimeout: 5 * time.Second, MaxRounds: 3, StaleTrustFactor: 0.5}
+
+// Trust a validator votes with, and whether it may vote at all given its last ping. Stale
+// validators are excluded, except in Availability mode, where they count at StaleTrustFactor
+// so blocks keep coming while pings lag.
+func voterTrust(v *ValidatorProfile) (trust float64, ok bool) {
+	if time.Since(v.LastPing) <= authTimeout {
+		return v.Trust, true
+	}
+	if currentState == Availability && consensusConfig.StaleTrustFactor > 0 {
+		return v.Trust * consensusConfig.StaleTrustFactor, true
+	}
+	return 0, false
+}
+
+var errNoQuorum = errors.New("no quorum reached within max rounds")
+
+// Chain settings a proposal is built against, copied before the proposer starts so a proposer
+// still running after its round never reads the live chain
+type ProposalChain struct {
+	ID       string
+	Hasher   Hasher
+	Bits     int // difficulty to mine at
+	MaxNonce int
+}
+
+// Snapshot of the active chain's settings; the caller holds the locks the proposal runs under
+func proposalChain() ProposalChain {
+	return ProposalChain{ID: chainID, Hasher: blockHasher, Bits: difficultyBits, MaxNonce: maxNonce}
+}
+
+// Builds the block a proposer puts forward for a round; should give up once ctx is done. It gets
+// its own copy of the template and may use only that and chain, never package state.
+type BlockProposer interface {
+	Propose(ctx context.Context, proposerID string, template Block, chain ProposalChain) (Block, error)
+}
+
+// Proposes by mining the template under the proposer's id
+type MiningProposer struct{}
+
+func (p *MiningProposer) Propose(ctx context.Context, proposerID string, template Block, chain ProposalChain) (Block, error) {
+	block := template
+	block.Validator = proposerID
+	stats, err := mineProposal(ctx, block, chain)
+	if err != nil {
+		return Block{}, err
+	}
+	block.Nonce, block.Bits = stats.Nonce, stats.Bits
+	fmt.Printf("Mined block %d in %d tries (expected ~%.0f) in %v\n", block.Index, stats.Tries, expectedTries(chain.Bits), stats.Duration)
+	block.sealFor(chain)
+	return block, nil
+}
+
+var blockProposer BlockProposer = &MiningProposer{}
+
+// Proposer rotation: the requested proposer leads view 0, the rest follow by descending
+// proposerScore for the slot after prevHash, ties broken by id
+func proposerRotation(first, prevHash string) []string {
+	consensusMu.RLock()
+	defer consensusMu.RUnlock()
+	return rankProposers(first, prevHash)
+}
+
+// proposerRotation for callers already holding consensusMu
+func rankProposers(first, prevHash string) []string {
+	var others []string
+	for id := range validators {
+		if id != first {
+			others = append(others, id)
+		}
+	}
+	sort.Strings(others)
+	sort.SliceStable(others, func(i, j int) bool {
+		return proposerScore(others[i], prevHash) > proposerScore(others[j], prevHash)
+	})
+	return append([]string{first}, others...)
+}
+
+// Runs views until a proposal reaches quorum or MaxRounds is exhausted; returns the winning view.
+// Each view's proposer is cancelled at RoundTimeout, and cancelling ctx stops the rounds altogether.
+func runConsensusRounds(ctx context.Context, template Block, firstProposer string) (Block, int, error) {
+	rotation := proposerRotation(firstProposer, template.PrevHash)
+	for view := 0; view < consensusConfig.MaxRounds; view++ {
+		proposer := rotation[view%len(rotation)]
+		fmt.Printf("Round %d: %s proposing\n", view, proposer)
+
+		block, err := proposeWithTimeout(ctx, proposer, template)
+		switch {
+		case ctx.Err() != nil:
+			return Block{}, 0, ctx.Err()
+		case errors.Is(err, errNonceExhausted):
+			// Every proposer mines at the same difficulty, so a view change would not help
+			return Block{}, 0, err
+		case errors.Is(err, context.DeadlineExceeded):
+			fmt.Printf("Round %d: %s timed out, view change\n", view, proposer)
+		case err != nil:
+			fmt.Printf("Round %d: %s failed to propose: %v, view change\n", view, proposer, err)
+		case dBFTConsensus(ctx, &block):
+			return block, view, nil
+		default:
+			fmt.Printf("Round %d: proposal from %s rejected, view change\n", view, proposer)
+		}
+	}
+	return Block{}, 0, errNoQuorum
+}
+
+// One view's proposal, bounded by RoundTimeout. A proposer still running when the round ends is
+// left behind; it works on its own copy of the template and chain settings, so it can't touch the
+// chain once the caller has released its locks.
+func proposeWithTimeout(ctx context.Context, proposer string, template Block) (Block, error) {
+	roundCtx, cancel := context.WithTimeout(ctx, consensusConfig.RoundTimeout)
+	defer cancel()
+
+	chain := proposalChain()
+	template.Transactions = slices.Clone(template.Transactions)
+
+	type proposal struct {
+		block Block
+		err   error
+	}
+	proposals := make(chan proposal, 1)
+	go func() {
+		block, err := blockProposer.Propose(roundCtx, proposer, template, chain)
+		proposals <- proposal{block, err}
+	}()
+
+	select {
+	case p := <-proposals:
+		return p.block, p.err
+	case <-roundCtx.Done():
+		return Block{}, roundCtx.Err()
+	}
+}
+
+// Outcome of a background consensus run
+type ConsensusResult struct {
+	Block    Block // the proposal, with its consensus record when accepted
+	Accepted bool
+	Err      error // set when the run couldn't take place
+}
+
+// Votes on block in the background and delivers the outcome on the returned channel, which
+// receives exactly one result and is then closed. A run takes the same locks as a synchronous
+// proposal: chainMu shared, the forest write lock, then consensusMu. It therefore never races with
+// the producer, handlers or CAP changes on validator, forest or mode state. Replicas don't vote
+// and answer with errReplica.
+func ProposeAsync(block Block) <-chan ConsensusResult {
+	results := make(chan ConsensusResult, 1)
+	if nodeRole == RoleReplica {
+		results <- ConsensusResult{Block: block, Err: errReplica}
+		close(results)
+		return results
+	}
+	go func() {
+		defer close(results)
+		chainMu.RLock()
+		defer chainMu.RUnlock()
+		defer lockForestWrite()()
+		accepted := dBFTConsensus(context.Background(), &block)
+		results <- ConsensusResult{Block: block, Accepted: accepted}
+	}()
+	return results
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run with -race: background consensus runs share validator, forest and mode state with the
+// producer and the CAP orchestrator
+func TestProposeAsyncAlongsideProducer(t *testing.T) {
+	defer UseTestDifficulty()()
+	defer InstallTestForest(NewTestForest(2, 1))()
+	previousRetry, previousMode := syncRetry, currentState
+	defer func() { syncRetry, currentState = previousRetry, previousMode }()
+	syncRetry = &RetryController{Base: time.Millisecond, Max: time.Millisecond}
+
+	p := &Producer{interval: time.Second}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for tick := 0; tick < 3; tick++ {
+			p.produceRound(context.Background(), tick)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 3; i++ {
+			CAPOrchestrator()
+		}
+	}()
+	var results []<-chan ConsensusResult
+	for i := 0; i < 5; i++ {
+		results = append(results, ProposeAsync(MineTestBlock("async")))
+	}
+	for _, r := range results {
+		result, ok := <-r
+		if !ok || result.Err != nil {
+			t.Fatalf("ProposeAsync: %+v, open %t", result, ok)
+		}
+		if _, open := <-r; open {
+			t.Fatal("result channel not closed after its one result")
+		}
+	}
+	wg.Wait()
+}
+
+func TestProposeAsyncOnReplica(t *testing.T) {
+	previous := nodeRole
+	defer func() { nodeRole = previous }()
+	nodeRole = RoleReplica
+
+	result := <-ProposeAsync(Block{})
+	if result.Err != errReplica || result.Accepted {
+		t.Fatalf("replica: %+v, want errReplica", result)
+	}
+}
+
+// Stalls for the listed proposers until the round is cancelled and mines for everyone else
+type stallingProposer struct {
+	stalled map[string]bool
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (p *stallingProposer) Propose(ctx context.Context, proposerID string, template Block, chain ProposalChain) (Block, error) {
+	p.mu.Lock()
+	p.calls = append(p.calls, proposerID)
+	p.mu.Unlock()
+	if p.stalled[proposerID] {
+		<-ctx.Done()
+		return Block{}, ctx.Err()
+	}
+	return (&MiningProposer{}).Propose(ctx, proposerID, template, chain)
+}
+
+// Swaps in proposer and a short round timeout for one test
+func useTestProposer(proposer BlockProposer) (restore func()) {
+	previousProposer, previousConfig := blockProposer, consensusConfig
+	blockProposer = proposer
+	consensusConfig.RoundTimeout = 50 * time.Millisecond
+	return func() { blockProposer, consensusConfig = previousProposer, previousConfig }
+}
+
+func TestViewChangeAfterProposerTimeout(t *testing.T) {
+	defer UseTestDifficulty()()
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"A": testValidator(0.9, "US"),
+		"B": testValidator(0.9, "EU"),
+		"C": testValidator(0.9, "AS"),
+	})()
+	defer useConsensusStubs(fixedVote(true), &countingProofProvider{})()
+	proposer := &stallingProposer{stalled: map[string]bool{"A": true}}
+	defer useTestProposer(proposer)()
+
+	template := MineTestBlockAfter(MineTestBlock("genesis"), "round")
+	block, view, err := runConsensusRounds(context.Background(), template, "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proposer.mu.Lock()
+	defer proposer.mu.Unlock()
+	if view != 1 || len(proposer.calls) != 2 || proposer.calls[0] != "A" {
+		t.Fatalf("view %d after proposals from %v, want A to time out and view 1 to succeed", view, proposer.calls)
+	}
+	if block.Validator != proposer.calls[1] || block.Validator == "A" {
+		t.Fatalf("block proposed by %s, want the view 1 proposer %s", block.Validator, proposer.calls[1])
+	}
+}
+
+func TestRoundsGiveUpAfterMaxRounds(t *testing.T) {
+	defer UseTestDifficulty()()
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"A": testValidator(0.9, "US"),
+		"B": testValidator(0.9, "EU"),
+	})()
+	defer useConsensusStubs(fixedVote(true), &countingProofProvider{})()
+	proposer := &stallingProposer{stalled: map[string]bool{"A": true, "B": true}}
+	defer useTestProposer(proposer)()
+	consensusConfig.MaxRounds = 3
+
+	_, _, err := runConsensusRounds(context.Background(), MineTestBlock("genesis"), "A")
+	proposer.mu.Lock()
+	defer proposer.mu.Unlock()
+	if err != errNoQuorum || len(proposer.calls) != 3 {
+		t.Fatalf("%v after %d proposals, want errNoQuorum after 3", err, len(proposer.calls))
+	}
+}
+
+// Ignores ctx for the listed proposers: blocks until released, then mines anyway
+type deafProposer struct {
+	deaf    map[string]bool
+	release chan struct{}
+	late    chan Block // what a deaf proposer built after its round was over
+}
+
+func (p *deafProposer) Propose(ctx context.Context, proposerID string, template Block, chain ProposalChain) (Block, error) {
+	if !p.deaf[proposerID] {
+		return (&MiningProposer{}).Propose(ctx, proposerID, template, chain)
+	}
+	<-p.release
+	block, err := (&MiningProposer{}).Propose(context.Background(), proposerID, template, chain)
+	p.late <- block
+	return block, err
+}
+
+func TestRoundMovesOnFromAProposerIgnoringCancellation(t *testing.T) {
+	defer UseTestDifficulty()()
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"A": testValidator(0.9, "US"),
+		"B": testValidator(0.9, "EU"),
+		"C": testValidator(0.9, "AS"),
+	})()
+	defer useConsensusStubs(fixedVote(true), &countingProofProvider{})()
+	proposer := &deafProposer{deaf: map[string]bool{"A": true}, release: make(chan struct{}), late: make(chan Block, 1)}
+	defer useTestProposer(proposer)()
+	bits := difficultyBits
+
+	done := make(chan error, 1)
+	go func() {
+		_, view, err := runConsensusRounds(context.Background(), MineTestBlock("genesis"), "A")
+		if err == nil && view != 1 {
+			err = fmt.Errorf("accepted in view %d, want 1", view)
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		close(proposer.release)
+		t.Fatal("round waited on a proposer that ignores cancellation")
+	}
+
+	// The abandoned proposer finishes against the chain as it was when its round began
+	SetDifficulty(testDifficulty + 1)
+	close(proposer.release)
+	if late := <-proposer.late; late.Bits != bits || late.Validator != "A" {
+		t.Fatalf("late proposal mined at %d bits by %s, want %d bits by A", late.Bits, late.Validator, bits)
+	}
+}
+
+// Approves only the listed block hashes
+type approveHashes map[string]bool
+
+func (a approveHashes) Vote(id string, v *ValidatorProfile, blockHash string) (bool, float64) {
+	if a[blockHash] {
+		return true, 1
+	}
+	return false, 0
+}
+
+func TestProposeAsyncReturnsEachBlocksOwnResult(t *testing.T) {
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"A": testValidator(0.9, "US"),
+		"B": testValidator(0.9, "EU"),
+		"C": testValidator(0.9, "AS"),
+	})()
+	approved := approveHashes{}
+	for i := 0; i < 8; i += 2 {
+		approved[fmt.Sprint("proposal-", i)] = true
+	}
+	defer useConsensusStubs(approved, &countingProofProvider{})()
+
+	results := make(map[string]<-chan ConsensusResult)
+	for i := 0; i < 8; i++ {
+		hash := fmt.Sprint("proposal-", i)
+		results[hash] = ProposeAsync(Block{BlockHeader: BlockHeader{Hash: hash}})
+	}
+	for hash, r := range results {
+		result := <-r
+		if result.Err != nil || result.Block.Hash != hash {
+			t.Fatalf("%s: got result %+v", hash, result)
+		}
+		if result.Accepted != approved[hash] {
+			t.Fatalf("%s: accepted %t, want %t", hash, result.Accepted, approved[hash])
+		}
+		if result.Accepted && result.Block.Consensus == nil {
+			t.Fatalf("%s: accepted without a consensus record", hash)
+		}
+	}
+}
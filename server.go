@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Guards the forest between HTTP handlers: submissions write, queries read
+var forestMu sync.RWMutex
+
+// Body of POST /blocks
+type submitRequest struct {
+	Data      string `json:"data"`
+	Validator string `json:"validator"`
+}
+
+// Readiness report returned by /readyz
+type readinessStatus struct {
+	Ready   bool   `json:"ready"`
+	Shards  int    `json:"shards"`
+	Heights []int  `json:"heights"`
+	Error   string `json:"error,omitempty"`
+}
+
+// How the HTTP service listens. TLS is on when both TLSCert and TLSKey are set; with ClientCAs as
+// well, block submission additionally requires a client certificate signed by one of those CAs.
+type ServerConfig struct {
+	Addr      string
+	TLSCert   string // PEM certificate path
+	TLSKey    string // PEM private key path
+	ClientCAs string // PEM bundle of CAs trusted to sign peer certificates
+
+	ProofWorkers int // concurrent proof generations; defaultProofWorkers when zero
+}
+
+const defaultProofWorkers = 4
+
+// HTTP endpoints for running the chain as a service
+func newServer(cfg ServerConfig) *http.ServeMux {
+	submit := handleSubmitBlock
+	if cfg.ClientCAs != "" {
+		submit = requirePeerCert(submit)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", onActiveChain(handleHealthz))
+	mux.HandleFunc("GET /readyz", onActiveChain(handleReadyz))
+	mux.HandleFunc("GET /shards", onActiveChain(handleShards))
+	mux.HandleFunc("GET /metrics", onActiveChain(handleMetrics))
+	mux.HandleFunc("POST /blocks", onActiveChain(submit))
+	mux.HandleFunc("GET /shards/{shard}/blocks/{block}/proof", onActiveChain(handleProof(newProofServiceFor(cfg))))
+	return mux
+}
+
+// Holds chainMu shared for the request, so a Chain.Do can't swap the active chain mid-request
+func onActiveChain(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chainMu.RLock()
+		defer chainMu.RUnlock()
+		next(w, r)
+	}
+}
+
+func newProofServiceFor(cfg ServerConfig) *ProofService {
+	workers := cfg.ProofWorkers
+	if workers == 0 {
+		workers = defaultProofWorkers
+	}
+	return NewProofService(workers, 16*workers)
+}
+
+// Inclusion proof for one block, generated on the bounded proof workers
+func handleProof(proofs *ProofService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		shardIndex, err1 := strconv.Atoi(r.PathValue("shard"))
+		blockIndex, err2 := strconv.Atoi(r.PathValue("block"))
+		if err1 != nil || err2 != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "shard and block must be integers"})
+			return
+		}
+		proof, err := proofs.Request(shardIndex, blockIndex)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, proof)
+	}
+}
+
+// Serves the endpoints until the listener fails, over TLS when configured
+func serve(cfg ServerConfig) error {
+	server := &http.Server{Addr: cfg.Addr, Handler: newServer(cfg)}
+	if cfg.TLSCert == "" || cfg.TLSKey == "" {
+		return server.ListenAndServe()
+	}
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return err
+	}
+	server.TLSConfig = tlsConfig
+	return server.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+}
+
+// Client certs are verified when presented but only demanded by the submit endpoint,
+// so health probes keep working without one
+func (cfg ServerConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.ClientCAs == "" {
+		return tlsConfig, nil
+	}
+	pem, err := os.ReadFile(cfg.ClientCAs)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("client CAs %s: no PEM certificates found", cfg.ClientCAs)
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return tlsConfig, nil
+}
+
+// Rejects requests that did not present a client certificate verified against ClientCAs
+func requirePeerCert(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "client certificate required"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Submits a block through the same path as addBlockToShards; 429 when the validator is rate limited,
+// 503 when the shard is mid-maintenance, 403 on a replica
+func handleSubmitBlock(w http.ResponseWriter, r *http.Request) {
+	var req submitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Validator == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expected JSON body with data and validator"})
+		return
+	}
+
+	receipt, err := AddBlockCtx(r.Context(), req.Data, req.Validator)
+
+	switch {
+	case errors.Is(err, errRateLimited):
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": err.Error()})
+	case errors.Is(err, errShardBusy):
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+	case errors.Is(err, errReplica):
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusCreated, map[string]any{"status": "accepted", "receipt": receipt})
+	}
+}
+
+// Shard load statistics and the forest-wide imbalance
+func handleShards(w http.ResponseWriter, r *http.Request) {
+	forestMu.RLock()
+	defer forestMu.RUnlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"shards":    ShardStats(),
+		"imbalance": ForestImbalance(),
+	})
+}
+
+// Liveness: the process is up and serving
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readiness: AMQ filters and genesis set up for every shard and the forest validates
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	forestMu.RLock()
+	defer forestMu.RUnlock()
+	status := readinessStatus{Shards: len(merkleForest), Heights: []int{}}
+	for _, shard := range merkleForest {
+		status.Heights = append(status.Heights, shardHeight(shard))
+	}
+
+	var err error
+	if len(merkleForest) != shardCount || len(amqFilters) != shardCount {
+		err = errNotInitialized
+	} else {
+		err = validateForest()
+	}
+
+	code := http.StatusOK
+	status.Ready = err == nil
+	if err != nil {
+		status.Error = err.Error()
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, status)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
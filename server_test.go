@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getJSON(t *testing.T, handler http.HandlerFunc, path string, body any) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if err := json.NewDecoder(rec.Body).Decode(body); err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	return rec.Code
+}
+
+func TestHealthz(t *testing.T) {
+	var body map[string]string
+	if code := getJSON(t, handleHealthz, "/healthz", &body); code != http.StatusOK || body["status"] != "ok" {
+		t.Fatalf("healthz: %d %v", code, body)
+	}
+}
+
+func TestReadyzBeforeAndAfterGenesis(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 2})
+	merkleForest, amqFilters = nil, nil
+
+	var status readinessStatus
+	if code := getJSON(t, handleReadyz, "/readyz", &status); code != http.StatusServiceUnavailable || status.Ready {
+		t.Fatalf("before genesis: %d %+v, want 503", code, status)
+	}
+
+	initForest(GenesisConfig{ShardCount: 2})
+	status = readinessStatus{}
+	if code := getJSON(t, handleReadyz, "/readyz", &status); code != http.StatusOK || !status.Ready {
+		t.Fatalf("after genesis: %d %+v, want 200", code, status)
+	}
+	if status.Shards != 2 || len(status.Heights) != 2 {
+		t.Fatalf("readyz reports %d shards, heights %v", status.Shards, status.Heights)
+	}
+}
+
+func TestReadyzReportsInvalidForest(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1})
+	merkleForest[0].MerkleRoot = "tampered"
+
+	var status readinessStatus
+	if code := getJSON(t, handleReadyz, "/readyz", &status); code != http.StatusServiceUnavailable || status.Error == "" {
+		t.Fatalf("tampered root: %d %+v, want 503 with an error", code, status)
+	}
+}
+
+// Self-signed CA, or a leaf signed by parent when parent is given
+func testCert(t *testing.T, name string, parent *tls.Certificate, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	signer, signerKey := template, any(key)
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestMutualTLSGuardsSubmission(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1})
+	defer useConsensusStubs(fixedVote(true), &countingProofProvider{})()
+
+	ca := testCert(t, "peers", nil, x509.ExtKeyUsageClientAuth)
+	peer := testCert(t, "peer", &ca, x509.ExtKeyUsageClientAuth)
+	rogueCA := testCert(t, "rogue", nil, x509.ExtKeyUsageClientAuth)
+	rogue := testCert(t, "rogue peer", &rogueCA, x509.ExtKeyUsageClientAuth)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Certificate[0]}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := ServerConfig{ClientCAs: caFile}
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(newServer(cfg))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	clientWith := func(certs ...tls.Certificate) *http.Client {
+		transport := server.Client().Transport.(*http.Transport).Clone() // no connection reuse across clients
+		transport.TLSClientConfig.Certificates = certs
+		return &http.Client{Transport: transport}
+	}
+	submit := func(client *http.Client) (int, error) {
+		resp, err := client.Post(server.URL+"/blocks", "application/json", strings.NewReader(`{"data":"over tls","validator":"Validator1"}`))
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	if code, err := submit(clientWith(peer)); err != nil || code != http.StatusCreated {
+		t.Fatalf("peer with a valid cert: %d, %v", code, err)
+	}
+	if code, err := submit(clientWith()); err != nil || code != http.StatusForbidden {
+		t.Fatalf("client without a cert: %d, %v; want 403", code, err)
+	}
+	if code, err := submit(clientWith(rogue)); err == nil && code == http.StatusCreated {
+		t.Fatal("cert from an untrusted CA was allowed to submit")
+	}
+	resp, err := clientWith().Get(server.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("healthz without a cert: %d", resp.StatusCode)
+	}
+}
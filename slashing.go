@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// A validator's signed vote for the block at one shard height
+type SignedVote struct {
+	Validator string
+	Shard     int
+	Height    int
+	BlockHash string
+	Signature []byte
+}
+
+// Bytes the validator signs
+func (v SignedVote) message() []byte {
+	return []byte(fmt.Sprintf("vote:%d:%d:%s", v.Shard, v.Height, v.BlockHash))
+}
+
+func signVote(id string, shard, height int, blockHash string) (SignedVote, bool) {
+	consensusMu.RLock()
+	defer consensusMu.RUnlock()
+	vote := SignedVote{Validator: id, Shard: shard, Height: height, BlockHash: blockHash}
+	sig, ok := signAsValidator(id, vote.message())
+	vote.Signature = sig
+	return vote, ok
+}
+
+// Equivocation evidence: two votes by one validator for different blocks at the same shard height.
+// It is self-contained, so any node can check it against the validator's public key.
+type SlashingEvidence struct {
+	VoteA SignedVote
+	VoteB SignedVote
+}
+
+var errEvidenceAlreadyApplied = errors.New("evidence already applied")
+
+// Evidence ids already acted on, so resubmitting the same evidence doesn't slash twice
+var appliedEvidence = make(map[string]bool)
+
+// Order-independent id of a piece of evidence
+func (ev SlashingEvidence) id() string {
+	a, b := string(ev.VoteA.message()), string(ev.VoteB.message())
+	if b < a {
+		a, b = b, a
+	}
+	sum := sha256.Sum256([]byte(ev.VoteA.Validator + "|" + a + "|" + b))
+	return hex.EncodeToString(sum[:])
+}
+
+// Checks the votes conflict and are both genuinely signed by the accused validator
+func (ev SlashingEvidence) verify() error {
+	a, b := ev.VoteA, ev.VoteB
+	if a.Validator != b.Validator {
+		return fmt.Errorf("votes are from different validators")
+	}
+	if a.Shard != b.Shard || a.Height != b.Height {
+		return fmt.Errorf("votes are for different shard heights")
+	}
+	if a.BlockHash == b.BlockHash {
+		return fmt.Errorf("votes do not conflict")
+	}
+	if _, ok := validators[a.Validator]; !ok {
+		return fmt.Errorf("unknown validator %s", a.Validator)
+	}
+	for _, vote := range []SignedVote{a, b} {
+		if !verifyValidatorSignature(vote.Validator, vote.message(), vote.Signature) {
+			return fmt.Errorf("invalid signature on vote for %s", shortHash(vote.BlockHash))
+		}
+	}
+	return nil
+}
+
+// Verifies equivocation evidence and slashes the validator: its stake is forfeited and its
+// trust halved. The outcome depends only on the evidence, so every node slashes identically.
+func SubmitEvidence(ev SlashingEvidence) error {
+	consensusMu.Lock()
+	if err := ev.verify(); err != nil {
+		consensusMu.Unlock()
+		return fmt.Errorf("rejected evidence: %w", err)
+	}
+	id := ev.id()
+	if appliedEvidence[id] {
+		consensusMu.Unlock()
+		return errEvidenceAlreadyApplied
+	}
+	appliedEvidence[id] = true
+
+	v := validators[ev.VoteA.Validator]
+	v.StakeLevel = 0
+	v.Trust *= 0.5
+	consensusMu.Unlock()
+
+	fmt.Printf("Slashed %s for equivocating at shard %d height %d\n", ev.VoteA.Validator, ev.VoteA.Shard, ev.VoteA.Height)
+	if err := SaveValidators(); err != nil {
+		fmt.Println("Storage error:", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEquivocationEvidenceSlashes(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1})
+	a, okA := signVote("Validator1", 0, 1, "aaaa")
+	b, okB := signVote("Validator1", 0, 1, "bbbb")
+	if !okA || !okB {
+		t.Fatal("Validator1 could not sign")
+	}
+	trust := validators["Validator1"].Trust
+
+	if err := SubmitEvidence(SlashingEvidence{VoteA: a, VoteB: b}); err != nil {
+		t.Fatal(err)
+	}
+	v := validators["Validator1"]
+	if v.StakeLevel != 0 || v.Trust != trust*0.5 {
+		t.Fatalf("after slashing stake %d trust %.2f, want 0 and %.2f", v.StakeLevel, v.Trust, trust*0.5)
+	}
+	// The same evidence, even with its votes swapped, slashes only once
+	if err := SubmitEvidence(SlashingEvidence{VoteA: b, VoteB: a}); !errors.Is(err, errEvidenceAlreadyApplied) {
+		t.Fatalf("resubmitted evidence: %v", err)
+	}
+}
+
+func TestForgedEvidenceIsRejected(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1})
+	a, _ := signVote("Validator2", 0, 1, "aaaa")
+	b, _ := signVote("Validator2", 0, 1, "bbbb")
+	other, _ := signVote("Validator3", 0, 1, "bbbb")
+	otherHeight, _ := signVote("Validator2", 0, 2, "bbbb")
+	forged := b
+	forged.BlockHash = "cccc" // signature no longer covers the vote
+	stolen := other
+	stolen.Validator = "Validator2" // Validator3's signature passed off as Validator2's
+
+	cases := map[string]SlashingEvidence{
+		"forged signature":    {VoteA: a, VoteB: forged},
+		"another's signature": {VoteA: a, VoteB: stolen},
+		"same block":          {VoteA: a, VoteB: a},
+		"different heights":   {VoteA: a, VoteB: otherHeight},
+		"different signers":   {VoteA: a, VoteB: other},
+	}
+	stake := validators["Validator2"].StakeLevel
+	for name, ev := range cases {
+		if err := SubmitEvidence(ev); err == nil {
+			t.Errorf("%s: evidence accepted", name)
+		}
+	}
+	if validators["Validator2"].StakeLevel != stake {
+		t.Fatal("rejected evidence still slashed the validator")
+	}
+}
+
+// Another node holds none of the signer's secrets, only the keys it registered
+func withoutSeeds(t *testing.T, ids ...string) {
+	t.Helper()
+	saved := make(map[string][]byte)
+	for _, id := range ids {
+		saved[id] = keyring.seeds[id]
+		delete(keyring.seeds, id)
+	}
+	t.Cleanup(func() {
+		for id, seed := range saved {
+			keyring.seeds[id] = seed
+		}
+	})
+}
+
+func TestEvidenceVerifiesWithoutTheSignersSeed(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1})
+	a, _ := signVote("Validator1", 0, 1, "aaaa")
+	b, _ := signVote("Validator1", 0, 1, "bbbb")
+	qc, err := buildQuorumCertificate("aaaa", []string{"Validator1", "Validator2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := gatherCheckpointSignatures(0)
+	withoutSeeds(t, "Validator1", "Validator2")
+
+	if !verifyQuorumCertificate(qc) {
+		t.Fatal("quorum certificate rejected without the signers' seeds")
+	}
+	if _, ok := cp.Signatures["Validator1"]; !ok {
+		t.Fatal("Validator1 did not sign the checkpoint")
+	}
+	if !verifyValidatorSignature("Validator1", cp.message(), cp.Signatures["Validator1"]) {
+		t.Fatal("checkpoint signature rejected without the signer's seed")
+	}
+	if err := SubmitEvidence(SlashingEvidence{VoteA: a, VoteB: b}); err != nil {
+		t.Fatalf("evidence rejected without the signer's seed: %v", err)
+	}
+	if _, ok := keyring.seeds["Validator1"]; ok {
+		t.Fatal("verification regenerated the signer's seed")
+	}
+}
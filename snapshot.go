@@ -0,0 +1,27 @@
+package main
+
+// Point-in-time copy of one shard
+type ShardSnapshot struct {
+	Root        string
+	Height      int
+	BlockHashes []string
+}
+
+// Immutable view of the forest; later appends do not affect it
+type ForestSnapshot struct {
+	Shards     []ShardSnapshot
+	ForestRoot string
+}
+
+// Deep-copies shard roots, heights and block hashes
+func Snapshot() ForestSnapshot {
+	snap := ForestSnapshot{ForestRoot: ForestRoot()}
+	for _, shard := range merkleForest {
+		snap.Shards = append(snap.Shards, ShardSnapshot{
+			Root:        shard.MerkleRoot,
+			Height:      shardHeight(shard),
+			BlockHashes: blockHashes(shard.Blocks),
+		})
+	}
+	return snap
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestSnapshotIgnoresLaterAppends(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 2})
+	snap := Snapshot()
+	heights := []int{snap.Shards[0].Height, snap.Shards[1].Height}
+	hashes := append([]string(nil), snap.Shards[0].BlockHashes...)
+	root := snap.ForestRoot
+
+	for i := 0; i < 3; i++ {
+		tip := merkleForest[0].Blocks[len(merkleForest[0].Blocks)-1]
+		if err := acceptBlock(0, MineTestBlockAfter(tip, "later")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, shard := range snap.Shards {
+		if shard.Height != heights[i] {
+			t.Fatalf("shard %d height moved from %d to %d", i, heights[i], shard.Height)
+		}
+	}
+	if len(snap.Shards[0].BlockHashes) != len(hashes) || snap.Shards[0].BlockHashes[0] != hashes[0] {
+		t.Fatal("snapshot block hashes changed")
+	}
+	if snap.ForestRoot != root || ForestRoot() == root {
+		t.Fatal("snapshot root should stay put while the live root moves on")
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var errNotFound = errors.New("not found")
+
+// Store persists shard blocks (by position in the shard) and shard roots, plus small named
+// metadata blobs such as the validator registry.
+// A root is stored with the number of blocks it commits to, so entries past that height are ignored on load.
+type Store interface {
+	PutBlock(shardIndex, position int, block Block) error
+	GetBlock(shardIndex, position int) (Block, error)
+	PutRoot(shardIndex int, root string, height int) error
+	GetRoot(shardIndex int) (root string, height int, err error)
+	PutMeta(key string, value []byte) error
+	GetMeta(key string) ([]byte, error)
+}
+
+// Active storage backend; forest operations write through it
+var store Store = newMemoryStore()
+
+// --- In-memory backend ---
+
+type blockKey struct {
+	shard, position int
+}
+
+type storedRoot struct {
+	Root   string
+	Height int
+}
+
+type MemoryStore struct {
+	mu     sync.RWMutex
+	blocks map[blockKey]Block
+	roots  map[int]storedRoot
+	meta   map[string][]byte
+}
+
+func newMemoryStore() *MemoryStore {
+	return &MemoryStore{blocks: make(map[blockKey]Block), roots: make(map[int]storedRoot), meta: make(map[string][]byte)}
+}
+
+func (s *MemoryStore) PutBlock(shardIndex, position int, block Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blocks[blockKey{shardIndex, position}] = block
+	return nil
+}
+
+func (s *MemoryStore) GetBlock(shardIndex, position int) (Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	block, ok := s.blocks[blockKey{shardIndex, position}]
+	if !ok {
+		return Block{}, errNotFound
+	}
+	return block, nil
+}
+
+func (s *MemoryStore) PutRoot(shardIndex int, root string, height int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roots[shardIndex] = storedRoot{Root: root, Height: height}
+	return nil
+}
+
+func (s *MemoryStore) GetRoot(shardIndex int) (string, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.roots[shardIndex]
+	if !ok {
+		return "", 0, errNotFound
+	}
+	return r.Root, r.Height, nil
+}
+
+func (s *MemoryStore) PutMeta(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.meta[key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (s *MemoryStore) GetMeta(key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.meta[key]
+	if !ok {
+		return nil, errNotFound
+	}
+	return append([]byte(nil), value...), nil
+}
+
+// --- BoltDB backend ---
+
+var (
+	blocksBucket = []byte("blocks")
+	rootsBucket  = []byte("roots")
+	metaBucket   = []byte("meta")
+)
+
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func openBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{blocksBucket, rootsBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// Big-endian keys keep a shard's blocks contiguous and ordered by position
+func boltKey(ints ...int) []byte {
+	key := make([]byte, 8*len(ints))
+	for i, n := range ints {
+		binary.BigEndian.PutUint64(key[8*i:], uint64(n))
+	}
+	return key
+}
+
+// Blocks are stored gzip-compressed; the hash inside still covers the uncompressed fields
+func compressBlock(block Block) ([]byte, error) {
+	raw, err := json.Marshal(block)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompressBlock(data []byte) (Block, []byte, error) {
+	var block Block
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return block, nil, err
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return block, nil, err
+	}
+	return block, raw, json.Unmarshal(raw, &block)
+}
+
+func (s *BoltStore) PutBlock(shardIndex, position int, block Block) error {
+	data, err := compressBlock(block)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(blocksBucket).Put(boltKey(shardIndex, position), data)
+	})
+}
+
+func (s *BoltStore) GetBlock(shardIndex, position int) (Block, error) {
+	var block Block
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(blocksBucket).Get(boltKey(shardIndex, position))
+		if data == nil {
+			return errNotFound
+		}
+		var err error
+		block, _, err = decompressBlock(data)
+		return err
+	})
+	return block, err
+}
+
+// Compressed bytes on disk and uncompressed encoding size of a stored block
+func (s *BoltStore) BlockSize(shardIndex, position int) (stored, raw int, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(blocksBucket).Get(boltKey(shardIndex, position))
+		if data == nil {
+			return errNotFound
+		}
+		_, uncompressed, err := decompressBlock(data)
+		stored, raw = len(data), len(uncompressed)
+		return err
+	})
+	return stored, raw, err
+}
+
+func (s *BoltStore) PutRoot(shardIndex int, root string, height int) error {
+	data, err := json.Marshal(storedRoot{Root: root, Height: height})
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(rootsBucket).Put(boltKey(shardIndex), data)
+	})
+}
+
+func (s *BoltStore) GetRoot(shardIndex int) (string, int, error) {
+	var r storedRoot
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(rootsBucket).Get(boltKey(shardIndex))
+		if data == nil {
+			return errNotFound
+		}
+		return json.Unmarshal(data, &r)
+	})
+	return r.Root, r.Height, err
+}
+
+func (s *BoltStore) PutMeta(key string, value []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put([]byte(key), value)
+	})
+}
+
+func (s *BoltStore) GetMeta(key string) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(metaBucket).Get([]byte(key))
+		if data == nil {
+			return errNotFound
+		}
+		value = append([]byte(nil), data...)
+		return nil
+	})
+	return value, err
+}
+
+// --- Forest persistence ---
+
+// Writes a shard's blocks from position `from` onward, then its root and height
+func persistShard(shardIndex, from int) error {
+	shard := merkleForest[shardIndex]
+	for pos := from; pos < len(shard.Blocks); pos++ {
+		if err := store.PutBlock(shardIndex, pos, shard.Blocks[pos]); err != nil {
+			return fmt.Errorf("persist shard %d block %d: %w", shardIndex, pos, err)
+		}
+	}
+	if err := store.PutRoot(shardIndex, shard.MerkleRoot, len(shard.Blocks)); err != nil {
+		return fmt.Errorf("persist shard %d root: %w", shardIndex, err)
+	}
+	return nil
+}
+
+// Writes every shard in full
+func persistForest() error {
+	for i := range merkleForest {
+		if err := persistShard(i, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replaces the active forest with the one persisted in the store. AMQ filters aren't stored, so
+// they are rebuilt from the loaded blocks and isInAMQ answers for them straight away.
+func LoadForest() error {
+	forest, err := loadForestFromStore(store, shardCount)
+	if err != nil {
+		return err
+	}
+	unlock := lockForestWrite()
+	merkleForest = forest
+	rebuildAMQFilters()
+	unlock()
+	return nil
+}
+
+// Reads shardCount shards back from a store, checking each against its stored root
+func loadForestFromStore(s Store, shards int) ([]Shard, error) {
+	var forest []Shard
+	for i := 0; i < shards; i++ {
+		root, height, err := s.GetRoot(i)
+		if err != nil {
+			return nil, fmt.Errorf("shard %d root: %w", i, err)
+		}
+		shard := Shard{MerkleRoot: root}
+		for pos := 0; pos < height; pos++ {
+			block, err := s.GetBlock(i, pos)
+			if err != nil {
+				return nil, fmt.Errorf("shard %d block %d: %w", i, pos, err)
+			}
+			shard.Blocks = append(shard.Blocks, block)
+			shard.accumulate(block.Hash)
+		}
+		if updateMerkleRoot(i, shard.Blocks) != root {
+			return nil, fmt.Errorf("shard %d: stored root does not match blocks", i)
+		}
+		forest = append(forest, shard)
+	}
+	return forest, nil
+}
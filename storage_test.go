@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Every backend, opened fresh for each test
+var storeBackends = map[string]func(t *testing.T) Store{
+	"memory": func(t *testing.T) Store { return newMemoryStore() },
+	"bolt": func(t *testing.T) Store {
+		s, err := openBoltStore(filepath.Join(t.TempDir(), "forest.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	},
+}
+
+func TestStoreConformance(t *testing.T) {
+	defer UseTestDifficulty()()
+	genesis := MineTestBlock("genesis")
+	next := MineTestBlockAfter(genesis, "next")
+
+	for name, open := range storeBackends {
+		t.Run(name, func(t *testing.T) {
+			s := open(t)
+			if _, err := s.GetBlock(0, 0); !errors.Is(err, errNotFound) {
+				t.Fatalf("GetBlock on empty store: %v, want errNotFound", err)
+			}
+			if _, _, err := s.GetRoot(0); !errors.Is(err, errNotFound) {
+				t.Fatalf("GetRoot on empty store: %v, want errNotFound", err)
+			}
+			if _, err := s.GetMeta("validators"); !errors.Is(err, errNotFound) {
+				t.Fatalf("GetMeta on empty store: %v, want errNotFound", err)
+			}
+
+			for pos, block := range []Block{genesis, next} {
+				if err := s.PutBlock(1, pos, block); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for pos, want := range []Block{genesis, next} {
+				got, err := s.GetBlock(1, pos)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Hash != want.Hash || calculateHash(got) != want.Hash {
+					t.Fatalf("block %d: got hash %s, want %s", pos, got.Hash, want.Hash)
+				}
+			}
+			if _, err := s.GetBlock(0, 0); !errors.Is(err, errNotFound) {
+				t.Fatalf("block stored under shard 1 found under shard 0: %v", err)
+			}
+
+			if err := s.PutRoot(1, "root-a", 1); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.PutRoot(1, "root-b", 2); err != nil {
+				t.Fatal(err)
+			}
+			if root, height, err := s.GetRoot(1); err != nil || root != "root-b" || height != 2 {
+				t.Fatalf("GetRoot: %q %d %v, want the latest root-b at height 2", root, height, err)
+			}
+
+			value := []byte("payload")
+			if err := s.PutMeta("key", value); err != nil {
+				t.Fatal(err)
+			}
+			value[0] = 'X'
+			if got, err := s.GetMeta("key"); err != nil || string(got) != "payload" {
+				t.Fatalf("GetMeta: %q %v, want an unaliased copy of payload", got, err)
+			}
+		})
+	}
+}
+
+func TestForestPersistsThroughEachBackend(t *testing.T) {
+	for name, open := range storeBackends {
+		t.Run(name, func(t *testing.T) {
+			useTestChain(t, GenesisConfig{ShardCount: 2})
+			store = open(t)
+			forest := NewTestForest(2, 3)
+			InstallTestForest(forest)
+			if err := persistForest(); err != nil {
+				t.Fatal(err)
+			}
+
+			merkleForest = nil
+			if err := LoadForest(); err != nil {
+				t.Fatal(err)
+			}
+			for i, shard := range merkleForest {
+				if shard.MerkleRoot != forest[i].MerkleRoot || len(shard.Blocks) != 3 {
+					t.Fatalf("shard %d reloaded with %d blocks, root %.12s", i, len(shard.Blocks), shard.MerkleRoot)
+				}
+			}
+		})
+	}
+}
+
+func TestCompressedBlockIsSmallerAndKeepsItsHash(t *testing.T) {
+	defer UseTestDifficulty()()
+	block := MineTestBlock(strings.Repeat("compressible ", 1000))
+	s := storeBackends["bolt"](t).(*BoltStore)
+	if err := s.PutBlock(0, 0, block); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, raw, err := s.BlockSize(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored >= raw {
+		t.Fatalf("stored %d bytes for a %d byte block", stored, raw)
+	}
+	got, err := s.GetBlock(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Hash != block.Hash || calculateHash(got) != block.Hash {
+		t.Fatalf("round-tripped block hashes to %.12s, want %.12s", calculateHash(got), block.Hash)
+	}
+}
+
+func TestLoadForestRebuildsAMQFilters(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 3})
+	forest := NewTestForest(3, 4)
+	InstallTestForest(forest)
+	if err := persistForest(); err != nil {
+		t.Fatal(err)
+	}
+
+	merkleForest, amqFilters = nil, nil
+	if err := LoadForest(); err != nil {
+		t.Fatal(err)
+	}
+	if len(amqFilters) != len(forest) {
+		t.Fatalf("load built %d filters for %d shards", len(amqFilters), len(forest))
+	}
+	for i, shard := range forest {
+		for _, block := range shard.Blocks {
+			if !isInAMQ(i, block.Hash) {
+				t.Fatalf("shard %d filter misses loaded block %.12s", i, block.Hash)
+			}
+		}
+	}
+}
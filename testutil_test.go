@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Fixture builders shared by the package's tests. Blocks are mined at testDifficulty, so call
+// UseTestDifficulty first when they will go through validation.
+
+const testDifficulty = 1 // leading zero hex characters in fixture blocks
+
+// Lowers the global difficulty to testDifficulty; call the returned func to restore the old one
+func UseTestDifficulty() (restore func()) {
+	previous := difficultyBits
+	SetDifficulty(testDifficulty)
+	return func() { difficultyBits = previous }
+}
+
+// Genesis-position block carrying data, mined at testDifficulty
+func MineTestBlock(data string) Block {
+	return mineTestBlock(Block{
+		BlockHeader: BlockHeader{Index: 0, Timestamp: formatBlockTime(genesisTime)},
+		BlockBody:   BlockBody{Data: data},
+	})
+}
+
+// Block extending prev, one second later, mined at testDifficulty
+func MineTestBlockAfter(prev Block, data string) Block {
+	ts, err := parseBlockTime(prev.Timestamp)
+	if err != nil {
+		ts = genesisTime
+	}
+	return mineTestBlock(Block{
+		BlockHeader: BlockHeader{
+			Index:     prev.Index + 1,
+			Timestamp: formatBlockTime(ts.Add(time.Second)),
+			PrevHash:  prev.Hash,
+			Validator: "Validator1",
+		},
+		BlockBody: BlockBody{Data: data},
+	})
+}
+
+// Block extending prev that carries txs, mined at testDifficulty
+func MineTestBlockWith(prev Block, txs ...Transaction) Block {
+	block := MineTestBlockAfter(prev, "transfers")
+	block.Transactions = txs
+	return mineTestBlock(block)
+}
+
+func mineTestBlock(block Block) Block {
+	stats, err := mineBlockAt(block, 4*testDifficulty)
+	if err != nil {
+		panic(fmt.Sprintf("mining test block %d: %v", block.Index, err))
+	}
+	block.Nonce, block.Bits = stats.Nonce, stats.Bits
+	block.seal()
+	return block
+}
+
+// Shard of `blocks` linked blocks (at least the genesis), with its root and accumulator set
+func NewTestShard(shardIndex, blocks int) Shard {
+	shard := newShard(shardIndex, MineTestBlock(fmt.Sprintf("Genesis Block %d", shardIndex)))
+	for i := 1; i < blocks; i++ {
+		prev := shard.Blocks[len(shard.Blocks)-1]
+		block := MineTestBlockAfter(prev, fmt.Sprintf("shard %d block %d", shardIndex, i))
+		shard.Blocks = append(shard.Blocks, block)
+		shard.accumulate(block.Hash)
+	}
+	shard.MerkleRoot = updateMerkleRoot(shardIndex, shard.Blocks)
+	return shard
+}
+
+// Forest of `shards` shards, each holding blocksPerShard blocks counting the genesis
+func NewTestForest(shards, blocksPerShard int) Forest {
+	forest := make(Forest, 0, shards)
+	for i := 0; i < shards; i++ {
+		forest = append(forest, NewTestShard(i, blocksPerShard))
+	}
+	return forest
+}
+
+// Makes forest the active one, with AMQ filters built from its blocks; call the returned func to
+// put the previous forest back
+func InstallTestForest(forest Forest) (restore func()) {
+	previousForest, previousCount, previousFilters := merkleForest, shardCount, amqFilters
+	merkleForest = forest
+	shardCount = len(forest)
+	rebuildAMQFilters()
+	return func() {
+		merkleForest, shardCount, amqFilters = previousForest, previousCount, previousFilters
+	}
+}
+
+// Makes a fresh chain built from cfg, mined at testDifficulty, the active one until the test ends.
+// It has its own validators, ledger, mempool, store and logs, as NewChain gives it.
+func useTestChain(t testing.TB, cfg GenesisConfig) *Chain {
+	t.Helper()
+	restoreDifficulty := UseTestDifficulty()
+	previous := activeChain()
+	c := NewChain(cfg)
+	c.activate()
+	t.Cleanup(func() {
+		previous.activate()
+		restoreDifficulty()
+	})
+	return c
+}
+
+// Makes set the validator registry, registering this node's keys for each validator; call the
+// returned func to put the previous one back
+func UseTestValidators(set map[string]*ValidatorProfile) (restore func()) {
+	previous := validators
+	validators = withLocalKeys(set)
+	return func() { validators = previous }
+}
+
+// Live validator with the given trust and full stake
+func testValidator(trust float64, location string) *ValidatorProfile {
+	return &ValidatorProfile{Trust: trust, StakeLevel: 3, Location: location, PublicKey: "pk-" + location, LastPing: time.Now()}
+}
+
+// Fails the test unless every shard in forest validates
+func MustValidate(t testing.TB, forest Forest) {
+	t.Helper()
+	if len(forest) == 0 {
+		t.Fatalf("validate: %v", errNotInitialized)
+	}
+	for i, shard := range forest {
+		if err := validateShard(i, shard); err != nil {
+			t.Fatalf("validate shard %d: %v", i, err)
+		}
+	}
+}
+
+func TestFixturesValidate(t *testing.T) {
+	defer UseTestDifficulty()()
+
+	forest := NewTestForest(3, 4)
+	MustValidate(t, forest)
+	for i, shard := range forest {
+		if len(shard.Blocks) != 4 {
+			t.Fatalf("shard %d: %d blocks, want 4", i, len(shard.Blocks))
+		}
+		for pos := 1; pos < len(shard.Blocks); pos++ {
+			if shard.Blocks[pos].PrevHash != shard.Blocks[pos-1].Hash {
+				t.Fatalf("shard %d block %d does not link to its parent", i, pos)
+			}
+		}
+	}
+
+	defer InstallTestForest(forest)()
+	if err := validateForest(); err != nil {
+		t.Fatalf("installed fixture forest: %v", err)
+	}
+	if len(amqFilters) != 3 {
+		t.Fatalf("%d AMQ filters, want 3", len(amqFilters))
+	}
+}
+
+func TestMineTestBlockMeetsTestDifficulty(t *testing.T) {
+	defer UseTestDifficulty()()
+
+	block := MineTestBlock("fixture")
+	if err := validateBlockPoW(block, Shard{}); err != nil {
+		t.Fatal(err)
+	}
+	next := MineTestBlockAfter(block, "next")
+	if next.Index != 1 || next.PrevHash != block.Hash {
+		t.Fatalf("MineTestBlockAfter: index %d prev %.12s, want 1 and %.12s", next.Index, next.PrevHash, block.Hash)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Spans go to whatever provider is registered with otel.SetTracerProvider; a no-op until then
+var tracer = otel.Tracer("adaptiveblockchain")
+
+// Marks the span failed when err is set, then ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestAddBlockProducesNestedSpans(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 2})
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+	previous := tracer
+	defer func() { tracer = previous }()
+	tracer = provider.Tracer("adaptiveblockchain")
+
+	if err := addBlockToShards("traced", "Validator1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A rejected view leaves earlier mining and voting spans; keep the last of each, from the
+	// view that committed
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	root, ok := spans["addBlockToShards"]
+	if !ok {
+		t.Fatalf("no addBlockToShards span among %d exported", len(spans))
+	}
+	for _, name := range []string{"mineBlock", "dBFTConsensus", "synchronizeStateAcrossShards"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("no %s span", name)
+		}
+		if span.SpanContext.TraceID() != root.SpanContext.TraceID() {
+			t.Errorf("%s is in a different trace", name)
+		}
+		if span.Parent.SpanID() != root.SpanContext.SpanID() {
+			t.Errorf("%s is not a child of addBlockToShards", name)
+		}
+	}
+
+	attrs := func(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+		m := make(map[attribute.Key]attribute.Value)
+		for _, kv := range span.Attributes {
+			m[kv.Key] = kv.Value
+		}
+		return m
+	}
+	if v, ok := attrs(root)["shard.index"]; !ok || v.AsInt64() < 0 {
+		t.Error("addBlockToShards span lacks shard.index")
+	}
+	if v, ok := attrs(spans["mineBlock"])["difficulty.bits"]; !ok || v.AsInt64() != 4*testDifficulty {
+		t.Errorf("mineBlock difficulty.bits = %v, want %d", v.AsInt64(), 4*testDifficulty)
+	}
+	consensus := attrs(spans["dBFTConsensus"])
+	if v, ok := consensus["votes.total"]; !ok || v.AsInt64() == 0 {
+		t.Error("dBFTConsensus span lacks a vote count")
+	}
+	if v, ok := consensus["consensus.accepted"]; !ok || !v.AsBool() {
+		t.Error("dBFTConsensus span does not record the accepted outcome")
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	defaultTrustHalfLife = time.Hour
+	trustBaseline        = 0.5  // idle validators above this trust level decay toward it
+	trustRecovery        = 0.05 // share of the gap to full trust regained per approving vote
+)
+
+var trustHalfLife = defaultTrustHalfLife
+
+// Sets how long an idle validator takes to lose half its lead over trustBaseline;
+// d <= 0 restores the default
+func SetTrustHalfLife(d time.Duration) {
+	if d <= 0 {
+		d = defaultTrustHalfLife
+	}
+	trustHalfLife = d
+}
+
+// Lowers trust toward trustBaseline for the time the validator has been idle since its last ping
+// or the last decay, whichever is later, halving the lead every trustHalfLife. Idling never raises
+// trust; only recoverTrust does
+func decayTrust(v *ValidatorProfile, now time.Time) {
+	from := v.LastPing
+	if v.trustDecayedAt.After(from) {
+		from = v.trustDecayedAt
+	}
+	v.trustDecayedAt = now
+	idle := now.Sub(from)
+	if idle <= 0 || v.Trust <= trustBaseline {
+		return
+	}
+	factor := math.Pow(0.5, float64(idle)/float64(trustHalfLife))
+	v.Trust = trustBaseline + (v.Trust-trustBaseline)*factor
+}
+
+// Bounds on ValidatorProfile.History, so the history term can't swamp trust in the vote score
+var historyMin, historyMax = -10, 10
+
+// Sets the History range; an empty range (lo > hi) is rejected and leaves the bounds unchanged
+func SetHistoryRange(lo, hi int) error {
+	if lo > hi {
+		return fmt.Errorf("history range %d..%d is empty", lo, hi)
+	}
+	historyMin, historyMax = lo, hi
+	return nil
+}
+
+// Adds delta to the validator's History, keeping it within historyMin..historyMax
+func adjustHistory(v *ValidatorProfile, delta int) {
+	v.History = clampHistory(v.History + delta)
+}
+
+func clampHistory(h int) int {
+	return min(max(h, historyMin), historyMax)
+}
+
+// Good behaviour: an approving vote wins back part of the gap to full trust
+func recoverTrust(v *ValidatorProfile) {
+	v.Trust = min(1, v.Trust+(1-v.Trust)*trustRecovery)
+}
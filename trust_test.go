@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestIdleTrustOnlyDecaysTowardBaseline(t *testing.T) {
+	defer SetTrustHalfLife(trustHalfLife)
+	SetTrustHalfLife(10 * time.Minute)
+	start := time.Now()
+
+	v := &ValidatorProfile{Trust: 0.9, LastPing: start}
+	decayTrust(v, start.Add(20*time.Minute))
+	want := trustBaseline + (0.9-trustBaseline)/4 // two half-lives leave a quarter of the gap
+	if math.Abs(v.Trust-want) > 1e-9 {
+		t.Fatalf("trust after two half-lives = %.4f, want %.4f", v.Trust, want)
+	}
+
+	// Decaying in steps covers each idle stretch once
+	stepped := &ValidatorProfile{Trust: 0.9, LastPing: start}
+	decayTrust(stepped, start.Add(10*time.Minute))
+	decayTrust(stepped, start.Add(20*time.Minute))
+	decayTrust(stepped, start.Add(20*time.Minute))
+	if math.Abs(stepped.Trust-v.Trust) > 1e-9 {
+		t.Fatalf("stepped decay reached %.4f, a single decay %.4f", stepped.Trust, v.Trust)
+	}
+
+	low := &ValidatorProfile{Trust: 0.1, LastPing: start}
+	decayTrust(low, start.Add(10*time.Minute))
+	if low.Trust != 0.1 {
+		t.Fatalf("idle low trust rose to %.4f, want it left at 0.1", low.Trust)
+	}
+
+	before := v.Trust
+	recoverTrust(v)
+	if v.Trust <= before || v.Trust > 1 {
+		t.Fatalf("good behaviour moved trust from %.4f to %.4f", before, v.Trust)
+	}
+}
+
+func TestHistoryIsClampedAndCapsTheScore(t *testing.T) {
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"A": testValidator(1, "US"),
+		"B": testValidator(1, "EU"),
+	})()
+	defer useConsensusStubs(fixedVote(true), &countingProofProvider{})()
+	for i := 0; i < 3*historyMax; i++ {
+		block := Block{BlockHeader: BlockHeader{Hash: fmt.Sprint("approved ", i)}}
+		if !dBFTConsensus(context.Background(), &block) {
+			t.Fatalf("round %d rejected", i)
+		}
+	}
+	if h := validators["A"].History; h != historyMax {
+		t.Fatalf("history after %d approvals = %d, want the cap %d", 3*historyMax, h, historyMax)
+	}
+
+	ceiling := 0.95 + float64(historyMax)*0.05
+	inflated := testValidator(1, "US")
+	inflated.History = 1000 // e.g. loaded from before the clamp existed
+	for i := 0; i < 256; i++ {
+		if _, score := (WeightedScoreStrategy{}).Vote("A", inflated, fmt.Sprint(i)); score > ceiling+1e-9 {
+			t.Fatalf("score %.3f above the ceiling %.3f", score, ceiling)
+		}
+	}
+
+	if err := SetHistoryRange(5, -5); err == nil {
+		t.Fatal("empty history range accepted")
+	}
+	v := &ValidatorProfile{}
+	for i := 0; i < 3*historyMax; i++ {
+		adjustHistory(v, -1)
+	}
+	if v.History != historyMin {
+		t.Fatalf("history after repeated penalties = %d, want the floor %d", v.History, historyMin)
+	}
+}
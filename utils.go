@@ -1,22 +1,167 @@
 package main
 
 import (
-	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 )
 
-// Hashing
+// Network identity folded into every block hash, so blocks can't be replayed on another deployment
+var chainID = defaultChainID
+
+const defaultChainID = "adaptive-blockchain"
+
+// Hash of the block's header with its body roots recomputed, so it commits to the body as it is now
 func calculateHash(block Block) string {
-	record := fmt.Sprintf("%d%s%s%s%d%s", block.Index, block.Timestamp, block.Data, block.PrevHash, block.Nonce, block.Validator)
-	hash := sha256.Sum256([]byte(record))
-	return hex.EncodeToString(hash[:])
+	return hashHeader(block.sealedHeader())
+}
+
+// Hashing
+func hashHeader(h BlockHeader) string {
+	return hashHeaderFor(h, chainID, blockHasher)
+}
+
+// hashHeader for the given chain id and hasher rather than the active chain's
+func hashHeaderFor(h BlockHeader, id string, hasher Hasher) string {
+	record := fmt.Sprintf("%s|%d%s%s%d/%d%s%s%s", id, h.Index, h.Timestamp, h.PrevHash, h.Nonce, h.Bits, h.Validator, h.TxRoot, h.DataHash)
+	if h.Encrypted {
+		record += "encrypted"
+	}
+	return hex.EncodeToString(hasher.Sum([]byte(record)))
+}
+
+// Counts leading zero bits rather than hex characters, allowing difficulty in 1-bit steps
+func hasLeadingZeroBits(hash string, bits int) bool {
+	raw, err := hex.DecodeString(hash)
+	if err != nil || bits > len(raw)*8 {
+		return false
+	}
+	for i := 0; i < bits/8; i++ {
+		if raw[i] != 0 {
+			return false
+		}
+	}
+	if rem := bits % 8; rem > 0 {
+		mask := byte(0xFF) << (8 - rem)
+		return raw[bits/8]&mask == 0
+	}
+	return true
+}
+
+// Constant-time equality for hex hashes, compared as decoded bytes so case doesn't matter.
+// Strings that aren't valid hex are compared as they are, still in constant time.
+func hashesEqual(a, b string) bool {
+	rawA, errA := hex.DecodeString(a)
+	rawB, errB := hex.DecodeString(b)
+	if errA != nil || errB != nil {
+		return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+	}
+	return subtle.ConstantTimeCompare(rawA, rawB) == 1
+}
+
+// First 10 hex characters of a hash, for log output
+func shortHash(hash string) string {
+	if len(hash) > 10 {
+		return hash[:10]
+	}
+	return hash
+}
+
+// Number of leading zero bits in a hex hash
+func leadingZeroBits(hash string) int {
+	raw, err := hex.DecodeString(hash)
+	if err != nil {
+		return 0
+	}
+	bits := 0
+	for _, b := range raw {
+		if b == 0 {
+			bits += 8
+			continue
+		}
+		for mask := byte(0x80); b&mask == 0; mask >>= 1 {
+			bits++
+		}
+		break
+	}
+	return bits
+}
+
+// Work a block's hash demonstrates: expected hashes to find that many leading zero bits
+func blockWork(block Block) uint64 {
+	bits := leadingZeroBits(block.Hash)
+	if bits > 63 {
+		bits = 63
+	}
+	return 1 << bits
+}
+
+// Cumulative work over a chain
+func chainWork(blocks []Block) uint64 {
+	var work uint64
+	for _, block := range blocks {
+		work += blockWork(block)
+	}
+	return work
+}
+
+// Cumulative work a chain claims through its header Bits
+func totalWork(blocks []Block) uint64 {
+	var work uint64
+	for _, block := range blocks {
+		work += 1 << min(max(block.Bits, 0), 63)
+	}
+	return work
 }
 
-func isValidHash(hash string, difficulty int) bool {
-	prefix := ""
-	for i := 0; i < difficulty; i++ {
-		prefix += "0"
+// Total claimed work of an imported chain, once every block's hash is recomputed from its
+// contents and shown to meet the difficulty it claims; a peer can't inflate work by lying
+// about Bits or by supplying a hash its block doesn't produce
+func verifyChainWork(blocks []Block) (uint64, error) {
+	for _, block := range blocks {
+		if !hashesEqual(block.Hash, calculateHash(block)) {
+			return 0, fmt.Errorf("block %d: hash mismatch", block.Index)
+		}
+		if !hasLeadingZeroBits(block.Hash, block.Bits) {
+			return 0, fmt.Errorf("block %d: claims %d bits of work, hash has %d", block.Index, block.Bits, leadingZeroBits(block.Hash))
+		}
+	}
+	return totalWork(blocks), nil
+}
+
+// Block timestamps are RFC 3339 with nanoseconds
+func formatBlockTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// Parses a block timestamp; also accepts the time.Time.String() form older blocks used
+func parseBlockTime(ts string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+		return t, nil
+	}
+	if i := strings.Index(ts, " m="); i >= 0 {
+		ts = ts[:i]
+	}
+	return time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", ts)
+}
+
+// Median timestamp of the last n blocks (unparseable timestamps are skipped)
+func medianTimePast(blocks []Block, n int) time.Time {
+	if len(blocks) > n {
+		blocks = blocks[len(blocks)-n:]
+	}
+	var times []time.Time
+	for _, block := range blocks {
+		if t, err := parseBlockTime(block.Timestamp); err == nil {
+			times = append(times, t)
+		}
+	}
+	if len(times) == 0 {
+		return time.Time{}
 	}
-	return hash[:difficulty] == prefix
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times[len(times)/2]
 }
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLeadingZeroBits(t *testing.T) {
+	pad := strings.Repeat("f", 62)
+	cases := []struct {
+		hash string
+		bits int
+		want bool
+	}{
+		{"07" + pad, 5, true},  // one zero nibble plus one zero bit
+		{"0f" + pad, 5, false}, // a single zero nibble only
+		{"0f" + pad, 4, true},
+		{"00" + pad, 8, true},
+		{"00" + pad, 9, false},
+		{"ff" + pad, 0, true},
+		{"zz" + pad, 0, false}, // not hex
+		{"00", 9, false},       // more bits than the hash holds
+	}
+	for _, c := range cases {
+		if got := hasLeadingZeroBits(c.hash, c.bits); got != c.want {
+			t.Errorf("hasLeadingZeroBits(%.4s…, %d) = %t, want %t", c.hash, c.bits, got, c.want)
+		}
+	}
+}
+
+func TestChainIDIsBoundIntoBlockHashes(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1, ChainID: "net-a"})
+	block := MineTestBlockAfter(merkleForest[0].Blocks[0], "same content")
+	hashA := calculateHash(block)
+
+	previous := chainID
+	chainID = "net-b"
+	hashB := calculateHash(block)
+	chainID = previous
+	if hashA == hashB {
+		t.Fatal("the same block hashes identically under two chain ids")
+	}
+
+	useTestChain(t, GenesisConfig{ShardCount: 1, ChainID: "net-b"})
+	genesis := merkleForest[0].Blocks[0]
+	// Links to this chain's genesis but is sealed under net-a, as a replay from there would be
+	chainID = "net-a"
+	foreign := MineTestBlockAfter(genesis, "replayed")
+	chainID = "net-b"
+	if err := ImportBlock(0, foreign); err == nil {
+		t.Fatal("block sealed on net-a imported into net-b")
+	}
+	if err := ImportBlock(0, MineTestBlockAfter(genesis, "replayed")); err != nil {
+		t.Fatalf("the same block sealed on net-b: %v", err)
+	}
+}
+
+func TestHashesEqual(t *testing.T) {
+	hash := calculateHash(Block{BlockBody: BlockBody{Data: "x"}})
+	flipped := "0" + hash[1:]
+	if hash[0] == '0' {
+		flipped = "1" + hash[1:]
+	}
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{hash, hash, true},
+		{hash, strings.ToUpper(hash), true}, // same bytes, different case
+		{hash, flipped, false},
+		{hash, hash[:62], false}, // prefix of the other
+		{hash, "", false},
+		{"", "", true},
+		{"not hex", "not hex", true},
+		{"not hex", "not hez", false},
+	}
+	for _, c := range cases {
+		if got := hashesEqual(c.a, c.b); got != c.want {
+			t.Errorf("hashesEqual(%.8q, %.8q) = %t, want %t", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestChainWorkRejectsOverstatedDifficulty(t *testing.T) {
+	defer UseTestDifficulty()()
+	honest := NewTestShard(0, 4).Blocks
+	work, err := verifyChainWork(honest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := uint64(len(honest)) << (4 * testDifficulty); work != want {
+		t.Fatalf("honest chain work %d, want %d", work, want)
+	}
+
+	lying := append([]Block(nil), honest...)
+	lying[2].Bits = 40 // claimed in the header and hashed in, but the hash doesn't meet it
+	lying[2].seal()
+	if totalWork(lying) <= work {
+		t.Fatal("the forged claim should inflate the claimed work")
+	}
+	if _, err := verifyChainWork(lying); err == nil {
+		t.Fatal("chain claiming 40 bits on a weak hash verified")
+	}
+
+	tampered := append([]Block(nil), honest...)
+	tampered[1].Bits = 40 // claim raised without re-sealing
+	if _, err := verifyChainWork(tampered); err == nil {
+		t.Fatal("chain with a header that no longer matches its hash verified")
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const medianTimeSpan = 11 // blocks considered by the median-time-past rule
+
+const minFutureSkew = 2 * time.Second // future-timestamp tolerance however fast the network is
+
+// How far ahead of the local clock a block's timestamp may be: twice the median RTT the latency
+// probe measures, on top of minFutureSkew, so slow links don't get honest blocks rejected
+func maxFutureSkew() time.Duration {
+	return minFutureSkew + 2*medianRTT()
+}
+
+var errNotInitialized = errors.New("forest not initialized")
+
+// Validates every shard in the forest: genesis present, block hashes and PoW intact, Merkle root current
+func validateForest() error {
+	if len(merkleForest) == 0 {
+		return errNotInitialized
+	}
+	for i, shard := range merkleForest {
+		if err := validateShard(i, shard); err != nil {
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Validates a single shard's blocks and stored Merkle root
+func validateShard(shardIndex int, shard Shard) error {
+	if len(shard.Blocks) == 0 {
+		return fmt.Errorf("no genesis block")
+	}
+	if shard.Blocks[0].Index != 0 {
+		return fmt.Errorf("first block has index %d, want genesis", shard.Blocks[0].Index)
+	}
+	for _, block := range shard.Blocks {
+		if err := validateBlockHash(block, shard); err != nil {
+			return err
+		}
+		if err := validateBlockPoW(block, shard); err != nil {
+			return err
+		}
+	}
+	if shard.MerkleRoot != updateMerkleRoot(shardIndex, shard.Blocks) {
+		return fmt.Errorf("stale Merkle root")
+	}
+	return nil
+}
+
+// BlockValidator is one acceptance rule applied to a block before it joins a shard
+type BlockValidator interface {
+	Validate(block Block, shard Shard) error
+}
+
+// Adapts a plain function to BlockValidator
+type BlockValidatorFunc func(block Block, shard Shard) error
+
+func (f BlockValidatorFunc) Validate(block Block, shard Shard) error {
+	return f(block, shard)
+}
+
+// Rules run in registration order; the built-in hash, PoW and linkage rules come first
+var blockValidators = []BlockValidator{
+	BlockValidatorFunc(validateBlockHash),
+	BlockValidatorFunc(validateBlockPoW),
+	BlockValidatorFunc(validateBlockLinkage),
+	BlockValidatorFunc(validateBlockGas),
+	BlockValidatorFunc(validateBlockTime),
+	BlockValidatorFunc(validateTxExpiry),
+}
+
+// Adds a custom acceptance rule, run after the existing ones
+func RegisterBlockValidator(v BlockValidator) {
+	blockValidators = append(blockValidators, v)
+}
+
+func runBlockValidators(block Block, shard Shard) error {
+	for _, v := range blockValidators {
+		if err := v.Validate(block, shard); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateBlockHash(block Block, shard Shard) error {
+	if !hashesEqual(block.Hash, calculateHash(block)) {
+		return fmt.Errorf("block %d: hash mismatch", block.Index)
+	}
+	if !block.Matches(block.BlockHeader) {
+		return fmt.Errorf("block %d: body does not match header roots", block.Index)
+	}
+	return nil
+}
+
+func validateBlockPoW(block Block, shard Shard) error {
+	if !hasLeadingZeroBits(block.Hash, difficultyBits) {
+		return fmt.Errorf("block %d: insufficient proof of work", block.Index)
+	}
+	if !hasLeadingZeroBits(block.Hash, block.Bits) {
+		return fmt.Errorf("block %d: claims %d bits of work, hash has %d", block.Index, block.Bits, leadingZeroBits(block.Hash))
+	}
+	return nil
+}
+
+// The block must extend the shard's current tip
+func validateBlockLinkage(block Block, shard Shard) error {
+	if len(shard.Blocks) == 0 {
+		return fmt.Errorf("shard has no genesis block")
+	}
+	tip := shard.Blocks[len(shard.Blocks)-1]
+	if block.PrevHash != tip.Hash {
+		return fmt.Errorf("block %d: does not extend shard tip", block.Index)
+	}
+	if block.Index != tip.Index+1 {
+		return fmt.Errorf("block %d: expected index %d", block.Index, tip.Index+1)
+	}
+	return nil
+}
+
+func validateBlockGas(block Block, shard Shard) error {
+	if gas := totalGas(block.Transactions); gas > blockGasLimit {
+		return fmt.Errorf("block %d: gas %d exceeds limit %d", block.Index, gas, blockGasLimit)
+	}
+	return nil
+}
+
+// Median-time-past: the timestamp must be later than the median of the previous blocks, and no
+// further ahead of the local clock than maxFutureSkew
+func validateBlockTime(block Block, shard Shard) error {
+	t, err := parseBlockTime(block.Timestamp)
+	if err != nil {
+		return fmt.Errorf("block %d: bad timestamp: %w", block.Index, err)
+	}
+	if mtp := medianTimePast(shard.Blocks, medianTimeSpan); !t.After(mtp) {
+		return fmt.Errorf("block %d: timestamp %s not after median time past %s", block.Index, block.Timestamp, formatBlockTime(mtp))
+	}
+	if skew := maxFutureSkew(); t.After(time.Now().Add(skew)) {
+		return fmt.Errorf("block %d: timestamp %s more than %s in the future", block.Index, block.Timestamp, skew)
+	}
+	return nil
+}
+
+// Censorship resistance: every transaction on the inclusion list must be in the block. The list
+// comes from the local mempool, which other nodes don't share, so it is checked only on blocks
+// this node proposes and never on imported or synced ones.
+func validateInclusionList(block Block, shard Shard) error {
+	carried := make(map[string]bool, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		carried[tx.Hash()] = true
+	}
+	for _, tx := range inclusionList(block.Index) {
+		if !carried[tx.Hash()] {
+			return fmt.Errorf("block %d: omits overdue transaction %s", block.Index, shortHash(tx.Hash()))
+		}
+	}
+	return nil
+}
+
+// Rejects a block carrying a transaction whose ExpiryHeight is below the block's index
+func validateTxExpiry(block Block, shard Shard) error {
+	for _, tx := range block.Transactions {
+		if tx.expiredAt(block.Index) {
+			return fmt.Errorf("block %d: transaction %s expired at height %d", block.Index, shortHash(tx.Hash()), tx.ExpiryHeight)
+		}
+	}
+	return nil
+}
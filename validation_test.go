@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var errEmptyData = errors.New("block has no data")
+
+func TestCustomValidatorRejectsEmptyData(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1})
+	previous := blockValidators
+	defer func() { blockValidators = previous }()
+	RegisterBlockValidator(BlockValidatorFunc(func(block Block, shard Shard) error {
+		if block.Data == "" {
+			return errEmptyData
+		}
+		return nil
+	}))
+
+	genesis := merkleForest[0].Blocks[0]
+	if err := ImportBlock(0, MineTestBlockAfter(genesis, "")); !errors.Is(err, errEmptyData) {
+		t.Fatalf("empty block: %v, want errEmptyData", err)
+	}
+	if err := ImportBlock(0, MineTestBlockAfter(genesis, "payload")); err != nil {
+		t.Fatalf("block with data: %v", err)
+	}
+	if len(merkleForest[0].Blocks) != 2 {
+		t.Fatalf("shard holds %d blocks, want genesis plus the accepted one", len(merkleForest[0].Blocks))
+	}
+}
+
+func TestBlockOverGasLimitIsRejected(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1, Balances: map[string]uint64{"alice": 100}})
+	previous := blockGasLimit
+	blockGasLimit = 10
+	defer func() { blockGasLimit = previous }()
+
+	genesis := merkleForest[0].Blocks[0]
+	heavy := MineTestBlockWith(genesis,
+		Transaction{From: "alice", To: "bob", Amount: 1, Nonce: 0, Gas: 6},
+		Transaction{From: "alice", To: "bob", Amount: 1, Nonce: 1, Gas: 6},
+	)
+	if err := ImportBlock(0, heavy); err == nil {
+		t.Fatal("block declaring 12 gas accepted under a limit of 10")
+	}
+	light := MineTestBlockWith(genesis, Transaction{From: "alice", To: "bob", Amount: 1, Nonce: 0, Gas: 10})
+	if err := ImportBlock(0, light); err != nil {
+		t.Fatalf("block at the limit: %v", err)
+	}
+}
+
+func TestBlockTimeMustExceedMedianTimePast(t *testing.T) {
+	var shard Shard
+	for i := 0; i < 11; i++ {
+		// Out of order, as miners' clocks drift; the median is genesisTime+5s either way
+		offset := time.Duration((i*7)%11) * time.Second
+		shard.Blocks = append(shard.Blocks, Block{BlockHeader: BlockHeader{Index: i, Timestamp: formatBlockTime(genesisTime.Add(offset))}})
+	}
+	if mtp := medianTimePast(shard.Blocks, medianTimeSpan); !mtp.Equal(genesisTime.Add(5 * time.Second)) {
+		t.Fatalf("median time past %s, want genesis + 5s", formatBlockTime(mtp))
+	}
+
+	stamped := func(offset time.Duration) Block {
+		return Block{BlockHeader: BlockHeader{Index: 11, Timestamp: formatBlockTime(genesisTime.Add(offset))}}
+	}
+	for _, offset := range []time.Duration{3 * time.Second, 5 * time.Second} {
+		if err := validateBlockTime(stamped(offset), shard); err == nil {
+			t.Fatalf("block at genesis + %s accepted at or below the median", offset)
+		}
+	}
+	if err := validateBlockTime(stamped(6*time.Second), shard); err != nil {
+		t.Fatalf("block above the median: %v", err)
+	}
+}
+
+func TestInclusionListEnforcedOnLocalProposals(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1, Balances: map[string]uint64{"alice": 100}})
+	pending := Transaction{From: "alice", To: "bob", Amount: 5}
+	mempool.Add(pending)
+	for i := 0; i < inclusionDelay; i++ {
+		mempool.blockAccepted()
+	}
+
+	genesis := merkleForest[0].Blocks[0]
+	omitting := MineTestBlockAfter(genesis, "censoring")
+	if err := validateInclusionList(omitting, merkleForest[0]); err == nil {
+		t.Fatal("block omitting an overdue transaction passed")
+	}
+	including := MineTestBlockWith(genesis, pending)
+	if err := validateInclusionList(including, merkleForest[0]); err != nil {
+		t.Fatalf("block carrying the overdue transaction: %v", err)
+	}
+
+	// Other nodes' mempools differ, so imported blocks are not held to this node's list
+	if err := ImportBlock(0, omitting); err != nil {
+		t.Fatalf("imported block: %v", err)
+	}
+}
+
+// Reports the same RTT samples every time
+type fixedLatency []time.Duration
+
+func (f fixedLatency) RTTs() []time.Duration { return append([]time.Duration(nil), f...) }
+
+func TestFutureSkewWidensWithLatency(t *testing.T) {
+	previous := latencyProbe
+	defer func() { latencyProbe = previous }()
+	shard := Shard{Blocks: []Block{{BlockHeader: BlockHeader{Timestamp: formatBlockTime(genesisTime)}}}}
+	ahead := Block{BlockHeader: BlockHeader{Index: 1, Timestamp: formatBlockTime(time.Now().Add(5 * time.Second))}}
+
+	latencyProbe = fixedLatency{10 * time.Millisecond, 20 * time.Millisecond, 5 * time.Millisecond}
+	if skew := maxFutureSkew(); skew != minFutureSkew+20*time.Millisecond {
+		t.Fatalf("skew at low latency %v, want the floor plus twice the 10ms median", skew)
+	}
+	if err := validateBlockTime(ahead, shard); err == nil {
+		t.Fatal("block 5s ahead accepted on a fast network")
+	}
+
+	latencyProbe = fixedLatency{3 * time.Second, 2 * time.Second, 4 * time.Second}
+	if err := validateBlockTime(ahead, shard); err != nil {
+		t.Fatalf("block 5s ahead with a 3s median RTT: %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const minCommitteeSize = 2 // validators that must remain registered
+
+// Registers a validator; it takes part from the next consensus round
+func AddValidator(id string, p ValidatorProfile) error {
+	consensusMu.Lock()
+	defer consensusMu.Unlock()
+	if id == "" {
+		return fmt.Errorf("validator id must not be empty")
+	}
+	if _, exists := validators[id]; exists {
+		return fmt.Errorf("validator %s already registered", id)
+	}
+	if p.LastPing.IsZero() {
+		p.LastPing = time.Now()
+	}
+	if err := registerKeys(id, &p); err != nil {
+		return err
+	}
+	validators[id] = &p
+	return nil
+}
+
+// Records a liveness ping from a registered validator. Trust decays for the idle time up to now
+// first, so pinging resets the idle clock without forgiving the time already spent idle.
+func Ping(id string) error {
+	consensusMu.Lock()
+	defer consensusMu.Unlock()
+	v, ok := validators[id]
+	if !ok {
+		return fmt.Errorf("validator %s not registered", id)
+	}
+	now := time.Now()
+	decayTrust(v, now)
+	v.LastPing = now
+	return nil
+}
+
+// Deregisters a validator unless that would shrink the committee below minCommitteeSize
+func RemoveValidator(id string) error {
+	consensusMu.Lock()
+	defer consensusMu.Unlock()
+	if _, exists := validators[id]; !exists {
+		return fmt.Errorf("validator %s not registered", id)
+	}
+	if len(validators)-1 < minCommitteeSize {
+		return fmt.Errorf("cannot remove %s: committee would drop below %d validators", id, minCommitteeSize)
+	}
+	delete(validators, id)
+	return nil
+}
+
+// Persistent part of a ValidatorProfile; LastPing is ephemeral and never serialized
+type validatorRecord struct {
+	ID         string  `json:"id"`
+	Trust      float64 `json:"trust"`
+	History    int     `json:"history"`
+	Location   string  `json:"location"`
+	PublicKey  string  `json:"publicKey"`
+	StakeLevel int     `json:"stakeLevel"`
+	VerifyKey  []byte  `json:"verifyKey,omitempty"`
+	BLSKey     []byte  `json:"blsKey,omitempty"`
+}
+
+// Profile with the persisted fields filled in and LastPing left zero
+func (r validatorRecord) profile() *ValidatorProfile {
+	return &ValidatorProfile{
+		Trust:      r.Trust,
+		History:    r.History,
+		Location:   r.Location,
+		PublicKey:  r.PublicKey,
+		StakeLevel: r.StakeLevel,
+		VerifyKey:  r.VerifyKey,
+		BLSKey:     r.BLSKey,
+	}
+}
+
+// Canonical serialization of the validator registry: records sorted by id, so equal sets give identical bytes
+func MarshalValidators() ([]byte, error) {
+	consensusMu.RLock()
+	defer consensusMu.RUnlock()
+	return marshalValidatorSet(validators)
+}
+
+func marshalValidatorSet(set map[string]*ValidatorProfile) ([]byte, error) {
+	var ids []string
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	records := make([]validatorRecord, 0, len(ids))
+	for _, id := range ids {
+		v := set[id]
+		records = append(records, validatorRecord{
+			ID:         id,
+			Trust:      v.Trust,
+			History:    v.History,
+			Location:   v.Location,
+			PublicKey:  v.PublicKey,
+			StakeLevel: v.StakeLevel,
+			VerifyKey:  v.VerifyKey,
+			BLSKey:     v.BLSKey,
+		})
+	}
+	return json.Marshal(records)
+}
+
+// Parses a MarshalValidators payload; LastPing is left zero until the validator next pings
+func UnmarshalValidators(data []byte) (map[string]*ValidatorProfile, error) {
+	var records []validatorRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	set := make(map[string]*ValidatorProfile, len(records))
+	for _, r := range records {
+		if _, dup := set[r.ID]; dup {
+			return nil, fmt.Errorf("duplicate validator %s", r.ID)
+		}
+		set[r.ID] = r.profile()
+	}
+	return set, nil
+}
+
+const validatorsMetaKey = "validators"
+
+// Persists the registry, including the trust and history consensus has evolved, through the store
+func SaveValidators() error {
+	data, err := MarshalValidators()
+	if err != nil {
+		return err
+	}
+	return store.PutMeta(validatorsMetaKey, data)
+}
+
+// Replaces the registry with the one last saved; reloaded validators count as freshly pinged
+func LoadValidators() error {
+	data, err := store.GetMeta(validatorsMetaKey)
+	if err != nil {
+		return err
+	}
+	set, err := UnmarshalValidators(data)
+	if err != nil {
+		return fmt.Errorf("load validators: %w", err)
+	}
+	for id, v := range set {
+		v.LastPing = time.Now()
+		if err := registerKeys(id, v); err != nil { // saved before keys were registered
+			return err
+		}
+	}
+	consensusMu.Lock()
+	validators = set
+	consensusMu.Unlock()
+	return nil
+}
+
+// ed25519 key derived from a keyring seed
+func ed25519SigningKey(seed []byte) ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(deriveKey(seed, "validator-ed25519"))
+}
+
+// Signing key of a registered validator, derived from its secret keyring seed
+func validatorSigningKey(id string) (ed25519.PrivateKey, bool) {
+	if _, ok := validators[id]; !ok {
+		return nil, false
+	}
+	seed, err := validatorSeed(id)
+	if err != nil {
+		return nil, false
+	}
+	return ed25519SigningKey(seed), true
+}
+
+// Verification key the validator registered; never derived from this node's keyring, so any
+// node can check the validator's signatures
+func validatorVerifyKey(id string) (ed25519.PublicKey, bool) {
+	v, ok := validators[id]
+	if !ok || len(v.VerifyKey) != ed25519.PublicKeySize {
+		return nil, false
+	}
+	return ed25519.PublicKey(v.VerifyKey), true
+}
+
+// Signs a message as the given validator
+func signAsValidator(id string, msg []byte) ([]byte, bool) {
+	key, ok := validatorSigningKey(id)
+	if !ok {
+		return nil, false
+	}
+	return ed25519.Sign(key, msg), true
+}
+
+// Checks a validator's signature over a message
+func verifyValidatorSignature(id string, msg, sig []byte) bool {
+	pub, ok := validatorVerifyKey(id)
+	return ok && ed25519.Verify(pub, msg, sig)
+}
+
+// Sum of StakeLevel over every registered validator
+func totalStake() int {
+	total := 0
+	for _, v := range validators {
+		total += v.StakeLevel
+	}
+	return total
+}
+
+// --- Validator set commitment ---
+
+// Leaf committing to one validator's id, stake and public keys
+func validatorLeaf(id string, v *ValidatorProfile) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("validator:%s:%d:%s:%x:%x", id, v.StakeLevel, v.PublicKey, v.VerifyKey, v.BLSKey)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Leaves of the validator set, sorted by id so the same set always gives the same tree
+func validatorSetLeaves() (ids, leaves []string) {
+	for id := range validators {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		leaves = append(leaves, validatorLeaf(id, validators[id]))
+	}
+	return ids, leaves
+}
+
+// Merkle root over the registered validators and their stakes; empty when there are none.
+// Recorded alongside a height, it pins down the committee that voted there.
+func validatorSetRoot() string {
+	consensusMu.RLock()
+	defer consensusMu.RUnlock()
+	_, leaves := validatorSetLeaves()
+	return merkleRootOfHashes(leaves)
+}
+
+// Proof that validator id, with its current stake and key, is in the set under validatorSetRoot
+func validatorSetProof(id string) (index int, proof []string, ok bool) {
+	consensusMu.RLock()
+	defer consensusMu.RUnlock()
+	ids, leaves := validatorSetLeaves()
+	index = sort.SearchStrings(ids, id)
+	if index == len(ids) || ids[index] != id {
+		return 0, nil, false
+	}
+	return index, merkleProofOfHashes(leaves, index), true
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestValidatorSetRoundTrip(t *testing.T) {
+	set := map[string]*ValidatorProfile{
+		"B": {Trust: 0.75, History: -2, Location: "EU", PublicKey: "pk-b", StakeLevel: 2, LastPing: time.Now()},
+		"A": {Trust: 0.5, History: 4, Location: "US", PublicKey: "pk-a", StakeLevel: 1},
+		"C": {Trust: 1, Location: "AS", PublicKey: "pk-c", StakeLevel: 3, LastPing: time.Unix(42, 0)},
+	}
+	defer UseTestValidators(set)()
+
+	data, err := MarshalValidators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		again, err := MarshalValidators()
+		if err != nil || !bytes.Equal(again, data) {
+			t.Fatalf("run %d serialized differently", i)
+		}
+	}
+	if bytes.Contains(data, []byte("LastPing")) {
+		t.Fatal("LastPing was serialized")
+	}
+
+	decoded, err := UnmarshalValidators(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(set) {
+		t.Fatalf("%d validators decoded, want %d", len(decoded), len(set))
+	}
+	for id, want := range set {
+		got := decoded[id]
+		if got == nil || got.Trust != want.Trust || got.History != want.History || got.Location != want.Location ||
+			got.PublicKey != want.PublicKey || got.StakeLevel != want.StakeLevel || !got.LastPing.IsZero() {
+			t.Fatalf("%s decoded as %+v, want %+v without LastPing", id, got, want)
+		}
+	}
+	if again, _ := marshalValidatorSet(decoded); !bytes.Equal(again, data) {
+		t.Fatal("re-encoding the decoded set changed the bytes")
+	}
+}
+
+func TestUnmarshalValidatorsRejectsDuplicates(t *testing.T) {
+	data := []byte(`[{"id":"A","trust":0.5},{"id":"A","trust":0.9}]`)
+	if _, err := UnmarshalValidators(data); err == nil {
+		t.Fatal("duplicate ids accepted")
+	}
+}
+
+// Validators with a vote on the consensus record of a freshly decided block
+func votersOn(t *testing.T, hash string) map[string]bool {
+	t.Helper()
+	block := Block{BlockHeader: BlockHeader{Hash: hash}}
+	if !dBFTConsensus(context.Background(), &block) || block.Consensus == nil {
+		t.Fatalf("block %s rejected", hash)
+	}
+	voters := make(map[string]bool)
+	for _, vote := range block.Consensus.Votes {
+		voters[vote.Validator] = true
+	}
+	return voters
+}
+
+func TestAddedValidatorVotesOnNextBlock(t *testing.T) {
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"A": testValidator(0.95, "US"),
+		"B": testValidator(0.95, "EU"),
+	})()
+	defer useConsensusStubs(fixedVote(true), &countingProofProvider{})()
+
+	if votersOn(t, "before")["C"] {
+		t.Fatal("C voted before it was added")
+	}
+	if err := AddValidator("C", *testValidator(0.95, "AS")); err != nil {
+		t.Fatal(err)
+	}
+	if !votersOn(t, "after")["C"] {
+		t.Fatal("added validator did not vote on the next block")
+	}
+	if err := AddValidator("C", *testValidator(0.5, "AS")); err == nil {
+		t.Fatal("duplicate registration accepted")
+	}
+}
+
+func TestRemovedValidatorIsExcluded(t *testing.T) {
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"A": testValidator(0.95, "US"),
+		"B": testValidator(0.95, "EU"),
+		"C": testValidator(0.95, "AS"),
+	})()
+	defer useConsensusStubs(fixedVote(true), &countingProofProvider{})()
+
+	if err := RemoveValidator("C"); err != nil {
+		t.Fatal(err)
+	}
+	if votersOn(t, "after")["C"] {
+		t.Fatal("removed validator still voted")
+	}
+	if err := RemoveValidator("B"); err == nil {
+		t.Fatalf("committee dropped below %d validators", minCommitteeSize)
+	}
+	if len(validators) != minCommitteeSize {
+		t.Fatalf("%d validators left, want %d", len(validators), minCommitteeSize)
+	}
+	if err := RemoveValidator("missing"); err == nil {
+		t.Fatal("removing an unknown validator succeeded")
+	}
+}
+
+func TestSlashedTrustSurvivesReload(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1})
+	offender := testValidator(0.9, "SA")
+	offender.History = -3
+	defer UseTestValidators(map[string]*ValidatorProfile{
+		"A": testValidator(0.9, "US"),
+		"B": testValidator(0.9, "EU"),
+		"C": testValidator(0.9, "AS"),
+		"D": offender,
+	})()
+	defer useConsensusStubs(rejectFrom{"D": true}, &countingProofProvider{})()
+
+	block := Block{BlockHeader: BlockHeader{Hash: "penalty"}}
+	dBFTConsensus(context.Background(), &block)
+	slashed, history := validators["D"].Trust, validators["D"].History
+	if slashed >= 0.9 {
+		t.Fatalf("trust %v after a penalized vote, want below 0.9", slashed)
+	}
+	if err := SaveValidators(); err != nil {
+		t.Fatal(err)
+	}
+
+	validators = defaultValidators() // as after a restart
+	if err := LoadValidators(); err != nil {
+		t.Fatal(err)
+	}
+	d := validators["D"]
+	if d == nil || d.Trust != slashed || d.History != history || len(validators) != 4 {
+		t.Fatalf("reloaded D as %+v, want trust %v history %d", d, slashed, history)
+	}
+	if time.Since(d.LastPing) > time.Minute {
+		t.Fatal("reloaded validator not counted as freshly pinged")
+	}
+}
+
+func TestValidatorSetRootTracksTheSet(t *testing.T) {
+	committee := func(ids ...string) map[string]*ValidatorProfile {
+		set := make(map[string]*ValidatorProfile)
+		for _, id := range ids {
+			set[id] = testValidator(0.9, id)
+		}
+		return set
+	}
+	restore := UseTestValidators(committee("A", "B", "C"))
+	root := validatorSetRoot()
+	restore()
+	defer UseTestValidators(committee("C", "A", "B"))()
+	if validatorSetRoot() != root {
+		t.Fatal("same set built in a different order has a different root")
+	}
+
+	if err := AddValidator("D", *testValidator(0.9, "D")); err != nil {
+		t.Fatal(err)
+	}
+	withD := validatorSetRoot()
+	if withD == root {
+		t.Fatal("adding a validator left the root unchanged")
+	}
+	index, proof, ok := validatorSetProof("D")
+	if !ok || !verifyMerkleProofOfHashes(validatorLeaf("D", validators["D"]), index, proof, withD) {
+		t.Fatal("membership proof for the added validator failed")
+	}
+	if err := RemoveValidator("D"); err != nil {
+		t.Fatal(err)
+	}
+	if validatorSetRoot() != root {
+		t.Fatal("removing the added validator did not restore the root")
+	}
+
+	validators["B"].StakeLevel = 1
+	if validatorSetRoot() == root {
+		t.Fatal("a stake change left the root unchanged")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Where and how accepted blocks are announced
+type WebhookConfig struct {
+	URL         string
+	MaxAttempts int           // deliveries tried per block before giving up
+	Timeout     time.Duration // per attempt
+	RetryBase   time.Duration // first backoff; doubles per attempt with jitter
+}
+
+var defaultWebhookConfig = WebhookConfig{MaxAttempts: 5, Timeout: 5 * time.Second, RetryBase: 500 * time.Millisecond}
+
+// JSON body POSTed for each accepted block
+type webhookPayload struct {
+	Shard     int    `json:"shard"`
+	Height    int    `json:"height"`
+	Hash      string `json:"hash"`
+	PrevHash  string `json:"prevHash"`
+	Validator string `json:"validator"`
+	Timestamp string `json:"timestamp"`
+}
+
+// Subscribes to block events and delivers each to cfg.URL in the background until ctx is done
+func StartWebhook(ctx context.Context, cfg WebhookConfig) {
+	events, unsubscribe := SubscribeBlocks()
+	client := &http.Client{Timeout: cfg.Timeout}
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-events:
+				if err := deliverWebhook(ctx, client, cfg, event); err != nil {
+					fmt.Println("Webhook error:", err)
+				}
+			}
+		}
+	}()
+}
+
+// POSTs one event, retrying with backoff on transport errors and non-2xx responses
+func deliverWebhook(ctx context.Context, client *http.Client, cfg WebhookConfig, event BlockEvent) error {
+	body, err := json.Marshal(webhookPayload{
+		Shard:     event.Shard,
+		Height:    event.Position,
+		Hash:      event.Block.Hash,
+		PrevHash:  event.Block.PrevHash,
+		Validator: event.Block.Validator,
+		Timestamp: event.Block.Timestamp,
+	})
+	if err != nil {
+		return err
+	}
+
+	retry := &RetryController{Base: cfg.RetryBase, Max: 30 * cfg.RetryBase}
+	for attempt := 1; ; attempt++ {
+		err = postWebhook(ctx, client, cfg.URL, body)
+		if err == nil {
+			return nil
+		}
+		if attempt >= cfg.MaxAttempts {
+			return fmt.Errorf("block %s: giving up after %d attempts: %w", shortHash(event.Block.Hash), attempt, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retry.NextDelay()):
+		}
+	}
+}
+
+func postWebhook(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookRetriesAfterAFailedDelivery(t *testing.T) {
+	useTestChain(t, GenesisConfig{ShardCount: 1})
+	var attempts atomic.Int32
+	delivered := make(chan webhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		var payload webhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		delivered <- payload
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	StartWebhook(ctx, WebhookConfig{URL: server.URL, MaxAttempts: 3, Timeout: time.Second, RetryBase: time.Millisecond})
+
+	block := MineTestBlockAfter(merkleForest[0].Blocks[0], "announced")
+	if err := acceptBlock(0, block); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case payload := <-delivered:
+		if payload.Hash != block.Hash || payload.Height != 1 || payload.PrevHash != block.PrevHash || payload.Shard != 0 {
+			t.Fatalf("payload %+v does not describe the accepted block", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook never delivered")
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Fatalf("%d delivery attempts, want a failure then one retry", n)
+	}
+}
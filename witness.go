@@ -0,0 +1,75 @@
+package main
+
+import "fmt"
+
+// Pre-state balance and nonce of one account plus its proof against the state root
+type AccountWitness struct {
+	Account string
+	Balance uint64
+	Nonce   uint64
+	Index   int
+	Proof   []string
+}
+
+// Witness carries everything a stateless verifier needs to check a block's transfers
+type Witness struct {
+	Accounts []AccountWitness
+}
+
+// Collects pre-state proofs for every account the block spends from
+func BuildWitness(block Block, state *State) Witness {
+	var witness Witness
+	seen := make(map[string]bool)
+	for _, tx := range block.Transactions {
+		if seen[tx.From] {
+			continue
+		}
+		seen[tx.From] = true
+		index, proof, ok := state.proveAccount(tx.From)
+		if !ok {
+			continue
+		}
+		witness.Accounts = append(witness.Accounts, AccountWitness{
+			Account: tx.From,
+			Balance: state.Balances[tx.From],
+			Nonce:   state.Nonces[tx.From],
+			Index:   index,
+			Proof:   proof,
+		})
+	}
+	return witness
+}
+
+// Checks the witness against stateRoot, then replays the block's transfers over the witnessed balances
+func VerifyWithWitness(block Block, witness Witness, stateRoot string) error {
+	if !hashesEqual(block.Hash, calculateHash(block)) {
+		return fmt.Errorf("block hash mismatch")
+	}
+	balances := make(map[string]uint64)
+	nonces := make(map[string]uint64)
+	for _, w := range witness.Accounts {
+		if !verifyMerkleProofOfHashes(accountLeaf(w.Account, w.Balance, w.Nonce), w.Index, w.Proof, stateRoot) {
+			return fmt.Errorf("witness for %s does not match state root", w.Account)
+		}
+		balances[w.Account] = w.Balance
+		nonces[w.Account] = w.Nonce
+	}
+	for i, tx := range block.Transactions {
+		balance, ok := balances[tx.From]
+		if !ok {
+			return fmt.Errorf("tx %d: no witness for sender %s", i, tx.From)
+		}
+		if err := checkNonce(nonces, tx); err != nil {
+			return fmt.Errorf("tx %d: %w", i, err)
+		}
+		if balance < tx.Amount {
+			return fmt.Errorf("tx %d: insufficient funds in %s", i, tx.From)
+		}
+		balances[tx.From] = balance - tx.Amount
+		nonces[tx.From]++
+		if _, ok := balances[tx.To]; ok {
+			balances[tx.To] += tx.Amount
+		}
+	}
+	return nil
+}
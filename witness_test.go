@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func witnessFixture(t *testing.T) (Block, *State) {
+	t.Helper()
+	state := newState()
+	state.Balances["alice"] = 100
+	state.Balances["bob"] = 5
+	state.Balances["carol"] = 0
+	block := MineTestBlockWith(MineTestBlock("genesis"),
+		Transaction{From: "alice", To: "carol", Amount: 40},
+		Transaction{From: "bob", To: "alice", Amount: 5},
+		Transaction{From: "alice", To: "bob", Amount: 10, Nonce: 1},
+	)
+	return block, state
+}
+
+func TestBlockVerifiesAgainstItsWitness(t *testing.T) {
+	defer UseTestDifficulty()()
+	block, state := witnessFixture(t)
+
+	witness := BuildWitness(block, state)
+	if len(witness.Accounts) != 2 {
+		t.Fatalf("witness covers %d accounts, want the 2 senders", len(witness.Accounts))
+	}
+	if err := VerifyWithWitness(block, witness, state.Root()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTamperedWitnessFails(t *testing.T) {
+	defer UseTestDifficulty()()
+	block, state := witnessFixture(t)
+	root := state.Root()
+
+	inflated := BuildWitness(block, state)
+	inflated.Accounts[0].Balance += 1000
+	if VerifyWithWitness(block, inflated, root) == nil {
+		t.Fatal("witness with an inflated balance verified")
+	}
+
+	renonced := BuildWitness(block, state)
+	renonced.Accounts[0].Nonce++
+	if VerifyWithWitness(block, renonced, root) == nil {
+		t.Fatal("witness with a changed nonce verified")
+	}
+
+	missing := BuildWitness(block, state)
+	missing.Accounts = missing.Accounts[1:]
+	if VerifyWithWitness(block, missing, root) == nil {
+		t.Fatal("witness without a sender verified")
+	}
+
+	if VerifyWithWitness(block, BuildWitness(block, state), "stale-root") == nil {
+		t.Fatal("witness verified against the wrong state root")
+	}
+}